@@ -0,0 +1,42 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrClosed = errors.New("broker closed")
+	//Ack/Nack的投递不存在：已经确认过，或者订阅者断开后消息已经重新入队
+	ErrUnknownTag = errors.New("unknown delivery tag")
+)
+
+//Message是投递到broker的消息，ID是消息服务里的消息id，消费者用它做幂等和确认消费
+type Message struct {
+	ID    string
+	Topic string
+	Body  []byte
+}
+
+//Delivery是推给订阅者的一次投递，Tag在这个broker里唯一，Ack/Nack用它
+type Delivery struct {
+	Message
+	Tag uint64
+	//这条消息之前投递过但是没有被确认
+	Redelivered bool
+}
+
+type Handler func(ctx context.Context, d Delivery)
+
+//Broker是消息服务投递消息的出口，RabbitMQ、ActiveMQ之类的适配器实现这个接口。
+//每个topic是一个队列，同一个topic的订阅者分摊消息，每条消息确认之前只投递给一个订阅者
+type Broker interface {
+	Publish(ctx context.Context, m Message) error
+	//Subscribe登记订阅后立即返回，在后台把消息推给handler，直到ctx结束；
+	//订阅结束时没有确认的消息重新入队
+	Subscribe(ctx context.Context, topic string, handler Handler) error
+	Ack(tag uint64) error
+	//Nack拒绝一次投递，requeue为true时放回队首重新投递，否则丢弃
+	Nack(tag uint64, requeue bool) error
+	Close() error
+}
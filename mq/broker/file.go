@@ -0,0 +1,160 @@
+package broker
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const fileName = "broker.log"
+
+type record struct {
+	Op    string `json:"op"`
+	Seq   uint64 `json:"seq"`
+	ID    string `json:"id,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	Body  []byte `json:"body,omitempty"`
+}
+
+//File是把消息写到本地文件的broker：发布的消息落盘之后才返回，确认或丢弃时追加一条删除记录，
+//重启后没有确认的消息重新入队。确认记录不落盘，崩溃时可能丢掉，这些消息会被再投递一次
+type File struct {
+	*core
+}
+
+type fileJournal struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+//OpenFile打开dir下的日志，按发布的顺序恢复没有确认的消息，并把日志压缩成只剩这些消息
+func OpenFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fileName)
+	live, maxSeq, err := replay(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*entry, 0, len(live))
+	for _, e := range live {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	j, err := compact(path, entries)
+	if err != nil {
+		return nil, err
+	}
+	c := newCore()
+	c.seq, c.journal = maxSeq, j
+	for _, e := range entries {
+		c.ready[e.m.Topic] = append(c.ready[e.m.Topic], e)
+	}
+	return &File{core: c}, nil
+}
+
+func replay(path string) (map[uint64]*entry, uint64, error) {
+	live := map[uint64]*entry{}
+	var maxSeq uint64
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return live, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var r record
+		//最后一行可能在崩溃时只写了一半
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			break
+		}
+		if r.Seq > maxSeq {
+			maxSeq = r.Seq
+		}
+		switch r.Op {
+		case "publish":
+			//重启前投递过的消息不知道有没有被处理，当作重新投递
+			live[r.Seq] = &entry{seq: r.Seq, m: Message{ID: r.ID, Topic: r.Topic, Body: r.Body}, redelivered: true}
+		case "remove":
+			delete(live, r.Seq)
+		}
+	}
+	return live, maxSeq, scanner.Err()
+}
+
+//compact把剩下的消息写到临时文件再替换原来的日志
+func compact(path string, entries []*entry) (*fileJournal, error) {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j := &fileJournal{f: f, w: bufio.NewWriter(f)}
+	for _, e := range entries {
+		if err := j.write(publishRecord(e)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := j.sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func publishRecord(e *entry) record {
+	return record{Op: "publish", Seq: e.seq, ID: e.m.ID, Topic: e.m.Topic, Body: e.m.Body}
+}
+
+func (j *fileJournal) write(r record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := j.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (j *fileJournal) sync() error {
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+func (j *fileJournal) append(e *entry) error {
+	if err := j.write(publishRecord(e)); err != nil {
+		return err
+	}
+	return j.sync()
+}
+
+func (j *fileJournal) remove(e *entry) error {
+	if err := j.write(record{Op: "remove", Seq: e.seq}); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+func (j *fileJournal) close() error {
+	if err := j.sync(); err != nil {
+		j.f.Close()
+		return err
+	}
+	return j.f.Close()
+}
@@ -0,0 +1,188 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+//每个订阅者最多同时有这么多没有确认的投递
+const defaultPrefetch = 16
+
+type entry struct {
+	seq         uint64
+	m           Message
+	redelivered bool
+}
+
+type subscriber struct {
+	unacked int
+}
+
+type inflight struct {
+	e     *entry
+	topic string
+	sub   *subscriber
+}
+
+//journal持久化消息，文件broker实现它，内存broker没有
+type journal interface {
+	append(e *entry) error
+	remove(e *entry) error
+	close() error
+}
+
+//core是内存和文件broker共用的队列
+type core struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	ready    map[string][]*entry
+	inflight map[uint64]*inflight
+	seq, tag uint64
+	prefetch int
+	journal  journal
+	closed   bool
+}
+
+func newCore() *core {
+	c := &core{ready: map[string][]*entry{}, inflight: map[uint64]*inflight{}, prefetch: defaultPrefetch}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+//Memory是进程内的broker，消息只在内存里，进程退出就没有了
+type Memory struct {
+	*core
+}
+
+func NewMemory() *Memory {
+	return &Memory{core: newCore()}
+}
+
+func (c *core) Publish(ctx context.Context, m Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	c.seq++
+	e := &entry{seq: c.seq, m: m}
+	if c.journal != nil {
+		if err := c.journal.append(e); err != nil {
+			return err
+		}
+	}
+	c.ready[m.Topic] = append(c.ready[m.Topic], e)
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *core) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	sub := &subscriber{}
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}()
+	go c.deliver(ctx, topic, sub, handler)
+	return nil
+}
+
+func (c *core) deliver(ctx context.Context, topic string, sub *subscriber, handler Handler) {
+	for {
+		c.mu.Lock()
+		for !c.closed && ctx.Err() == nil && (len(c.ready[topic]) == 0 || sub.unacked >= c.prefetch) {
+			c.cond.Wait()
+		}
+		if c.closed || ctx.Err() != nil {
+			c.requeue(sub)
+			c.mu.Unlock()
+			return
+		}
+		e := c.ready[topic][0]
+		c.ready[topic] = c.ready[topic][1:]
+		c.tag++
+		d := Delivery{Message: e.m, Tag: c.tag, Redelivered: e.redelivered}
+		c.inflight[c.tag] = &inflight{e: e, topic: topic, sub: sub}
+		sub.unacked++
+		c.mu.Unlock()
+
+		handler(ctx, d)
+	}
+}
+
+//requeue把断开的订阅者没有确认的消息放回队首
+func (c *core) requeue(sub *subscriber) {
+	for tag, f := range c.inflight {
+		if f.sub == sub {
+			delete(c.inflight, tag)
+			c.pushFront(f)
+		}
+	}
+	c.cond.Broadcast()
+}
+
+func (c *core) pushFront(f *inflight) {
+	f.e.redelivered = true
+	c.ready[f.topic] = append([]*entry{f.e}, c.ready[f.topic]...)
+}
+
+func (c *core) take(tag uint64) (*inflight, error) {
+	f, ok := c.inflight[tag]
+	if !ok {
+		return nil, ErrUnknownTag
+	}
+	delete(c.inflight, tag)
+	f.sub.unacked--
+	c.cond.Broadcast()
+	return f, nil
+}
+
+func (c *core) Ack(tag uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := c.take(tag)
+	if err != nil {
+		return err
+	}
+	if c.journal != nil {
+		return c.journal.remove(f.e)
+	}
+	return nil
+}
+
+func (c *core) Nack(tag uint64, requeue bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := c.take(tag)
+	if err != nil {
+		return err
+	}
+	if requeue {
+		c.pushFront(f)
+		return nil
+	}
+	if c.journal != nil {
+		return c.journal.remove(f.e)
+	}
+	return nil
+}
+
+func (c *core) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.cond.Broadcast()
+	if c.journal != nil {
+		return c.journal.close()
+	}
+	return nil
+}
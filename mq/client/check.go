@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/sysphusking/dsts/mq/proto"
+)
+
+//CheckFunc是生产者的回查函数：根据消息id查本地业务是否成功
+type CheckFunc func(ctx context.Context, req *pb.CheckRequest) (pb.CheckResponse_Result, error)
+
+type checkServer struct {
+	fn CheckFunc
+}
+
+func (s checkServer) Check(ctx context.Context, req *pb.CheckRequest) (*pb.CheckResponse, error) {
+	result, err := s.fn(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckResponse{Result: result}, nil
+}
+
+//RegisterCheck把回查函数注册到生产者已有的gRPC服务上，生产者预发送时用这个服务的地址作为Producer
+func RegisterCheck(s *grpc.Server, fn CheckFunc) {
+	pb.RegisterProducerServer(s, checkServer{fn: fn})
+}
+
+//ServeCheck在addr上单独启动回查服务，返回的grpc.Server用来停止它
+func ServeCheck(addr string, fn CheckFunc) (*grpc.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := grpc.NewServer()
+	RegisterCheck(s, fn)
+	go s.Serve(lis)
+	return s, nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	pb "github.com/sysphusking/dsts/mq/proto"
+)
+
+type Client struct {
+	Connection pb.MessageClient
+	//生产者的标识，预发送的消息都带着它，消息恢复时用它回查业务结果
+	Producer string
+	conn     *grpc.ClientConn
+}
+
+func New(addr, producer string) (*Client, error) {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Connection: pb.NewMessageClient(conn), Producer: producer, conn: conn}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) Prepare(ctx context.Context, topic string, body []byte) (string, error) {
+	m, err := c.Connection.Prepare(ctx, &pb.PrepareRequest{Topic: topic, Body: body, Producer: c.Producer})
+	if err != nil {
+		return "", err
+	}
+	return m.Id, nil
+}
+
+func (c *Client) Confirm(ctx context.Context, id string) error {
+	_, err := c.Connection.Confirm(ctx, &pb.MessageID{Id: id})
+	return err
+}
+
+func (c *Client) Cancel(ctx context.Context, id string) error {
+	_, err := c.Connection.Cancel(ctx, &pb.MessageID{Id: id})
+	return err
+}
+
+func (c *Client) Consumed(ctx context.Context, id string) error {
+	_, err := c.Connection.Consumed(ctx, &pb.MessageID{Id: id})
+	return err
+}
+
+func (c *Client) Get(ctx context.Context, id string) (*pb.MessageInfo, error) {
+	return c.Connection.Get(ctx, &pb.MessageID{Id: id})
+}
+
+//Send是生产者的完整流程：预发送成功后执行本地业务，成功就确认，失败就取消。
+//本地业务成功后确认失败不返回错误，消息恢复会回查业务结果再确认
+func (c *Client) Send(ctx context.Context, topic string, body []byte, local func(id string) error) (string, error) {
+	id, err := c.Prepare(ctx, topic, body)
+	if err != nil {
+		//预发送失败，不执行本地业务
+		return "", err
+	}
+	if err := local(id); err != nil {
+		if err := c.Cancel(ctx, id); err != nil {
+			log.Warnf("cancel message %s failed, it will be checked back: %v", id, err)
+		}
+		return id, err
+	}
+	if err := c.Confirm(ctx, id); err != nil {
+		log.Warnf("confirm message %s failed, it will be checked back: %v", id, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/mq/broker"
+	pb "github.com/sysphusking/dsts/mq/proto"
+)
+
+//Subscribe从消息服务内置的broker订阅topic，按顺序把消息交给fn处理：
+//返回nil时确认，返回错误时放回队首重新投递。一直阻塞到ctx结束或者连接断开
+func (c *Client) Subscribe(ctx context.Context, topic string, fn func(ctx context.Context, m broker.Message) error) error {
+	b := pb.NewBrokerClient(c.conn)
+	stream, err := b.Subscribe(ctx, &pb.SubscribeRequest{Topic: topic})
+	if err != nil {
+		return err
+	}
+	for {
+		d, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := fn(ctx, broker.Message{ID: d.Id, Topic: d.Topic, Body: d.Body}); err != nil {
+			log.Warnf("handle message %s: %v", d.Id, err)
+			_, err = b.Nack(ctx, &pb.NackRequest{Tag: d.Tag, Requeue: true})
+		} else {
+			_, err = b.Ack(ctx, &pb.AckRequest{Tag: d.Tag})
+		}
+		//确认失败时这次投递会在订阅断开后重新入队
+		if err != nil {
+			log.Warnf("ack delivery %d of message %s: %v", d.Tag, d.Id, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/mq/broker"
+)
+
+//Acker向消息服务确认消费，client.Client实现了这个接口
+type Acker interface {
+	Consumed(ctx context.Context, id string) error
+}
+
+//Handler在Ledger开启的本地事务里执行业务写入
+type Handler func(ctx context.Context, tx *sql.Tx, m broker.Message) error
+
+//Consumer是流程里的B系统：用Ledger保证同一条消息只处理一次，处理完向消息服务确认消费
+type Consumer struct {
+	Ledger *Ledger
+	Acker  Acker
+}
+
+func New(ledger *Ledger, acker Acker) *Consumer {
+	return &Consumer{Ledger: ledger, Acker: acker}
+}
+
+//Handle处理一次投递。重复的投递跳过业务但仍然确认消费，因为上一次的确认可能丢了。
+//返回错误时这次投递应当交还给broker重新投递：业务失败时什么都没有提交，
+//确认失败时业务已经提交，重新投递会被跳过
+func (c *Consumer) Handle(ctx context.Context, m broker.Message, fn Handler) error {
+	processed, err := c.Ledger.Process(ctx, m.ID, func(tx *sql.Tx) error {
+		return fn(ctx, tx, m)
+	})
+	if err != nil {
+		return err
+	}
+	if !processed {
+		log.Infof("skip duplicate delivery of message %s", m.ID)
+	}
+	return c.Acker.Consumed(ctx, m.ID)
+}
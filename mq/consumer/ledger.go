@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+//Ledger把处理过的消息id记在消费者自己的数据库里，和业务写入在同一个本地事务里提交，
+//所以业务写入成功的消息一定被记下，记下的消息业务一定写入过
+type Ledger struct {
+	db    *sql.DB
+	table string
+}
+
+func NewLedger(db *sql.DB) *Ledger {
+	return &Ledger{db: db, table: "consumed_message"}
+}
+
+//WithTable换一张表，多个消费者共用一个库时各自记自己的
+func (l *Ledger) WithTable(table string) *Ledger {
+	return &Ledger{db: l.db, table: table}
+}
+
+//Process开启本地事务，先插入消息id再执行fn，都成功才提交。
+//id已经存在时说明处理过了，不执行fn，返回false
+func (l *Ledger) Process(ctx context.Context, id string, fn func(tx *sql.Tx) error) (bool, error) {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	//同一条消息并发投递时后插入的会等先插入的事务结束，再因为主键冲突失败
+	if _, err := tx.ExecContext(ctx, "insert into "+l.table+"(id, consumed_at) values (?,?)", id, time.Now()); err != nil {
+		tx.Rollback()
+		//不依赖驱动的错误码：插入失败后再查一次，记录存在就是重复投递
+		if done, qerr := l.Processed(ctx, id); qerr == nil && done {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *Ledger) Processed(ctx context.Context, id string) (bool, error) {
+	var n int
+	if err := l.db.QueryRowContext(ctx, "select count(*) from "+l.table+" where id = ?", id).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+//Purge删除before之前的记录，保留的时间要比消息服务重新投递的时间长，否则过期之后的重复投递会被再处理一次
+func (l *Ledger) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := l.db.ExecContext(ctx, "delete from "+l.table+" where consumed_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,12 @@
+module github.com/sysphusking/dsts/mq
+
+go 1.13
+
+require (
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/golang/protobuf v1.4.2
+	github.com/google/uuid v1.1.2
+	github.com/sirupsen/logrus v1.2.0
+	google.golang.org/grpc v1.31.1
+	google.golang.org/protobuf v1.25.0
+)
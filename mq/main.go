@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/sysphusking/dsts/mq/broker"
+	"github.com/sysphusking/dsts/mq/recovery"
+	"github.com/sysphusking/dsts/mq/server"
+	"github.com/sysphusking/dsts/mq/service"
+)
+
+func main() {
+	addr := flag.String("addr", ":4000", "grpc listen address")
+	dsn := flag.String("dsn", "", "mysql dsn, e.g. root:password@tcp(localhost)/test?parseTime=true; empty keeps messages in memory")
+	brokerDir := flag.String("brokerdir", "", "directory of the built-in file broker; empty keeps queued messages in memory")
+	interval := flag.Duration("interval", 10*time.Second, "interval between recovery scans")
+	prepareTimeout := flag.Duration("preparetimeout", 2*time.Minute, "check back prepared messages older than this")
+	sendTimeout := flag.Duration("sendtimeout", 2*time.Minute, "redeliver sent messages not consumed within this, doubled after each attempt")
+	maxAttempts := flag.Int("maxattempts", 10, "mark messages dead after this many deliveries, 0 retries forever")
+	flag.Parse()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	var store service.MessageHandler = service.NewMemoryStore()
+	if *dsn != "" {
+		db, err := sql.Open("mysql", *dsn)
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+		store = service.NewMessageStore(db)
+	}
+
+	//内置的broker，消费者通过Broker.Subscribe收消息；接入外部MQ时换成它的适配器
+	var b broker.Broker = broker.NewMemory()
+	if *brokerDir != "" {
+		fb, err := broker.OpenFile(*brokerDir)
+		if err != nil {
+			panic(err)
+		}
+		b = fb
+	}
+	defer b.Close()
+
+	svc := service.NewService(store, b)
+	svc.Timeouts.Prepare, svc.Timeouts.Send = *prepareTimeout, *sendTimeout
+	s := server.NewServer(*addr, svc)
+	if err := s.Run(); err != nil {
+		panic(err)
+	}
+
+	//消息恢复：producer是生产者回查服务的gRPC地址
+	checker := recovery.NewGRPCChecker()
+	defer checker.Close()
+	worker := recovery.NewWorker(svc, checker)
+	worker.Interval, worker.MaxAttempts = *interval, *maxAttempts
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	<-ch
+	cancel()
+	<-done
+	s.Stop()
+}
@@ -0,0 +1,103 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/sysphusking/dsts/mq/proto"
+	"github.com/sysphusking/dsts/mq/service"
+)
+
+type CheckResult int
+
+const (
+	//业务还在进行中或者查不到结果，下次扫描再回查
+	Unknown CheckResult = iota
+	Succeeded
+	Failed
+)
+
+//Checker向生产者回查预发送消息对应的本地业务是否成功
+type Checker interface {
+	Check(ctx context.Context, m *service.Message) (CheckResult, error)
+}
+
+//CheckFunc让和消息服务在同一个进程里的生产者直接用函数回查
+type CheckFunc func(ctx context.Context, m *service.Message) (CheckResult, error)
+
+func (f CheckFunc) Check(ctx context.Context, m *service.Message) (CheckResult, error) {
+	return f(ctx, m)
+}
+
+//Checkers按消息的producer选择回查的方式，没有登记的producer交给Default
+type Checkers struct {
+	Producers map[string]Checker
+	Default   Checker
+}
+
+func (c Checkers) Check(ctx context.Context, m *service.Message) (CheckResult, error) {
+	if checker, ok := c.Producers[m.Producer]; ok {
+		return checker.Check(ctx, m)
+	}
+	if c.Default != nil {
+		return c.Default.Check(ctx, m)
+	}
+	return Unknown, fmt.Errorf("no checker for producer %q", m.Producer)
+}
+
+//GRPCChecker把消息的producer当作生产者的gRPC地址，调用它实现的Producer.Check
+type GRPCChecker struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func NewGRPCChecker() *GRPCChecker {
+	return &GRPCChecker{conns: map[string]*grpc.ClientConn{}}
+}
+
+func (g *GRPCChecker) Check(ctx context.Context, m *service.Message) (CheckResult, error) {
+	if m.Producer == "" {
+		return Unknown, fmt.Errorf("message %s has no producer", m.ID)
+	}
+	conn, err := g.dial(m.Producer)
+	if err != nil {
+		return Unknown, err
+	}
+	resp, err := pb.NewProducerClient(conn).Check(ctx, &pb.CheckRequest{Id: m.ID, Topic: m.Topic, Body: m.Body})
+	if err != nil {
+		return Unknown, err
+	}
+	switch resp.Result {
+	case pb.CheckResponse_SUCCEEDED:
+		return Succeeded, nil
+	case pb.CheckResponse_FAILED:
+		return Failed, nil
+	}
+	return Unknown, nil
+}
+
+func (g *GRPCChecker) dial(addr string) (*grpc.ClientConn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if conn, ok := g.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	g.conns[addr] = conn
+	return conn, nil
+}
+
+func (g *GRPCChecker) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for addr, conn := range g.conns {
+		conn.Close()
+		delete(g.conns, addr)
+	}
+}
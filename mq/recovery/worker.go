@@ -0,0 +1,104 @@
+package recovery
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/mq/service"
+)
+
+//Worker是消息恢复系统：定时找出到期的prepared和sent消息，
+//prepared的向生产者回查后确认或删除，sent的重新投递，次数用完改为dead。
+//到期时间（NextAttempt）由Service.Timeouts决定，没有处理完的消息会被推迟，不会一直占着每次扫描的名额
+type Worker struct {
+	Service *service.Service
+	Checker Checker
+	//扫描的间隔和每次每种状态最多处理的消息数
+	Interval  time.Duration
+	BatchSize int
+	//投递这么多次还没有被消费就改为dead，0表示一直重试
+	MaxAttempts int
+}
+
+func NewWorker(svc *service.Service, checker Checker) *Worker {
+	return &Worker{
+		Service:     svc,
+		Checker:     checker,
+		Interval:    10 * time.Second,
+		BatchSize:   100,
+		MaxAttempts: 10,
+	}
+}
+
+//Run每隔Interval扫描一次，直到ctx结束
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.RunOnce(ctx); err != nil {
+			log.Warn("message recovery: ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//RunOnce扫描一次，单条消息的错误只打日志，下次到期时再处理
+func (w *Worker) RunOnce(ctx context.Context) error {
+	prepared, err := w.Service.Pending(ctx, service.Prepared, w.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, m := range prepared {
+		w.check(ctx, m)
+	}
+
+	sent, err := w.Service.Pending(ctx, service.Sent, w.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, m := range sent {
+		w.redeliver(ctx, m)
+	}
+	return ctx.Err()
+}
+
+func (w *Worker) check(ctx context.Context, m *service.Message) {
+	result, err := w.Checker.Check(ctx, m)
+	if err != nil {
+		log.Warnf("check back message %s from %s: %v", m.ID, m.Producer, err)
+	}
+	switch {
+	case err == nil && result == Succeeded:
+		//业务成功了，消息还停在prepared时确认并发送
+		_, err = w.Service.ConfirmPrepared(ctx, m)
+	case err == nil && result == Failed:
+		err = w.Service.Cancel(ctx, m.ID)
+	default:
+		//结果未知或者回查失败，推迟到下一个周期
+		err = w.Service.Postpone(ctx, m.ID)
+	}
+	//扫描之后被生产者确认或者取消的消息不用再处理
+	if err != nil && err != service.ErrStateChanged && err != service.ErrMessageNotFound {
+		log.Warnf("recover prepared message %s: %v", m.ID, err)
+	}
+}
+
+func (w *Worker) redeliver(ctx context.Context, m *service.Message) {
+	var err error
+	if w.MaxAttempts > 0 && m.Attempts >= w.MaxAttempts {
+		log.Warnf("message %s not consumed after %d attempts, marking it dead", m.ID, m.Attempts)
+		_, err = w.Service.Kill(ctx, m.ID)
+	} else {
+		_, err = w.Service.Redeliver(ctx, m)
+	}
+	//扫描之后被确认消费的消息不用再处理
+	if err != nil && err != service.ErrStateChanged {
+		log.Warnf("redeliver message %s: %v", m.ID, err)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/mq/broker"
+	pb "github.com/sysphusking/dsts/mq/proto"
+)
+
+//Subscribe把消息服务用的broker暴露给其他进程里的消费者，流结束时订阅也结束
+func (s *Server) Subscribe(req *pb.SubscribeRequest, stream pb.Broker_SubscribeServer) error {
+	if req.Topic == "" {
+		return status.Error(codes.InvalidArgument, "topic is empty")
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	//broker在后台调用handler，Subscribe返回之后stream不能再用：返回前结束订阅，并等正在进行的Send结束
+	var (
+		mu    sync.Mutex
+		ended bool
+	)
+	defer func() {
+		cancel()
+		mu.Lock()
+		ended = true
+		mu.Unlock()
+	}()
+	b := s.Service.Broker
+	err := b.Subscribe(ctx, req.Topic, func(ctx context.Context, d broker.Delivery) {
+		mu.Lock()
+		defer mu.Unlock()
+		//订阅已经结束，这次投递不发送，和其他没有确认的消息一起重新入队
+		if ended || ctx.Err() != nil {
+			return
+		}
+		err := stream.Send(&pb.Delivery{Id: d.ID, Topic: d.Topic, Body: d.Body, Tag: d.Tag, Redelivered: d.Redelivered})
+		if err != nil {
+			//结束订阅，这条和其他没有确认的消息都会重新入队
+			log.Warnf("send delivery %d of message %s: %v", d.Tag, d.ID, err)
+			cancel()
+		}
+	})
+	if err != nil {
+		return brokerStatus(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-s.stopCh:
+	}
+	return nil
+}
+
+func (s *Server) Ack(ctx context.Context, req *pb.AckRequest) (*empty.Empty, error) {
+	if err := s.Service.Broker.Ack(req.Tag); err != nil {
+		return nil, brokerStatus(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *Server) Nack(ctx context.Context, req *pb.NackRequest) (*empty.Empty, error) {
+	if err := s.Service.Broker.Nack(req.Tag, req.Requeue); err != nil {
+		return nil, brokerStatus(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func brokerStatus(err error) error {
+	switch err {
+	case broker.ErrUnknownTag:
+		return status.Error(codes.NotFound, err.Error())
+	case broker.ErrClosed:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/sysphusking/dsts/mq/proto"
+	"github.com/sysphusking/dsts/mq/service"
+)
+
+//Server把消息服务和它的broker通过gRPC提供给生产者和消费者
+type Server struct {
+	Addr    string
+	Service *service.Service
+
+	grpcServer *grpc.Server
+	//关闭后结束所有订阅的流，否则GracefulStop会一直等消费者断开
+	stopCh chan struct{}
+}
+
+func NewServer(addr string, svc *service.Service) *Server {
+	return &Server{Addr: addr, Service: svc, stopCh: make(chan struct{})}
+}
+
+func (s *Server) Run() error {
+	lis, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	s.grpcServer = grpc.NewServer()
+	pb.RegisterMessageServer(s.grpcServer, s)
+	pb.RegisterBrokerServer(s.grpcServer, s)
+	log.Info("message service listening on ", s.Addr)
+	go func() {
+		if err := s.grpcServer.Serve(lis); err != nil {
+			log.Error("message service stopped: ", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Stop() {
+	close(s.stopCh)
+	if s.grpcServer != nil {
+		s.grpcServer.GracefulStop()
+	}
+}
+
+func (s *Server) Prepare(ctx context.Context, req *pb.PrepareRequest) (*pb.MessageInfo, error) {
+	m, err := s.Service.Prepare(ctx, req.Topic, req.Producer, req.Body)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toInfo(m), nil
+}
+
+func (s *Server) Confirm(ctx context.Context, req *pb.MessageID) (*pb.MessageInfo, error) {
+	m, err := s.Service.Confirm(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toInfo(m), nil
+}
+
+func (s *Server) Cancel(ctx context.Context, req *pb.MessageID) (*empty.Empty, error) {
+	if err := s.Service.Cancel(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *Server) Consumed(ctx context.Context, req *pb.MessageID) (*pb.MessageInfo, error) {
+	m, err := s.Service.Consumed(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toInfo(m), nil
+}
+
+func (s *Server) Get(ctx context.Context, req *pb.MessageID) (*pb.MessageInfo, error) {
+	m, err := s.Service.GetMessage(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toInfo(m), nil
+}
+
+func toInfo(m *service.Message) *pb.MessageInfo {
+	return &pb.MessageInfo{
+		Id:           m.ID,
+		Topic:        m.Topic,
+		Body:         m.Body,
+		Producer:     m.Producer,
+		State:        pb.State(m.State),
+		Attempts:     int32(m.Attempts),
+		Created:      m.CreatedAt.UnixNano(),
+		LastModified: m.LastModified.UnixNano(),
+	}
+}
+
+func toStatus(err error) error {
+	var stateErr *service.StateError
+	switch {
+	case errors.As(err, &stateErr):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case err == service.ErrMessageNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case err == service.ErrEmptyTopic:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case err == context.Canceled, err == context.DeadlineExceeded:
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	Prepared MessageState = iota
+	Sent
+	Consumed
+	Dead
+)
+
+type MessageState int
+
+func (s MessageState) String() string {
+	switch s {
+	case Prepared:
+		return "prepared"
+	case Sent:
+		return "sent"
+	case Consumed:
+		return "consumed"
+	case Dead:
+		return "dead"
+	}
+	return "unknown"
+}
+
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	//条件更新时消息已经不在期望的状态了，说明被别的请求改过
+	ErrStateChanged = errors.New("message state changed")
+)
+
+type MessageHandler interface {
+	Insert(ctx context.Context, m *Message) error
+	//UpdateState只在消息处于from状态时改为to，改为sent时投递次数加一，next是消息恢复下次处理它的时间
+	UpdateState(ctx context.Context, id string, from, to MessageState, next time.Time) (*Message, error)
+	//Postpone把处于state状态的消息推迟到next再处理
+	Postpone(ctx context.Context, id string, state MessageState, next time.Time) error
+	//Delete只删除处于state状态的消息
+	Delete(ctx context.Context, id string, state MessageState) error
+	GetMessage(ctx context.Context, id string) (*Message, error)
+	//GetMessagesInState按NextAttempt从早到晚返回在due之前到期的消息，最多limit条
+	GetMessagesInState(ctx context.Context, state MessageState, due time.Time, limit int) ([]*Message, error)
+}
+
+type Message struct {
+	ID       string       `json:"id"`
+	Topic    string       `json:"topic"`
+	Body     []byte       `json:"body"`
+	Producer string       `json:"producer"`
+	State    MessageState `json:"state"`
+	//已经投递的次数
+	Attempts     int       `json:"attempts"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastModified time.Time `json:"last_modified"`
+	//消息恢复下次回查或者重新投递的时间
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+func (m Message) TableName() string {
+	return "message"
+}
+
+const messageColumns = "id, topic, body, producer, state, attempts, created_at, last_modified, next_attempt"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row scanner) (*Message, error) {
+	m := &Message{}
+	if err := row.Scan(&m.ID, &m.Topic, &m.Body, &m.Producer, &m.State, &m.Attempts, &m.CreatedAt, &m.LastModified, &m.NextAttempt); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type MessageStore struct {
+	db *sql.DB
+}
+
+func NewMessageStore(db *sql.DB) *MessageStore {
+	return &MessageStore{db: db}
+}
+
+func (s *MessageStore) Insert(ctx context.Context, m *Message) error {
+	_, err := s.db.ExecContext(ctx, "insert into message("+messageColumns+") values (?,?,?,?,?,?,?,?,?)",
+		m.ID, m.Topic, m.Body, m.Producer, m.State, m.Attempts, m.CreatedAt, m.LastModified, m.NextAttempt)
+	return err
+}
+
+func (s *MessageStore) UpdateState(ctx context.Context, id string, from, to MessageState, next time.Time) (*Message, error) {
+	attempts := 0
+	if to == Sent {
+		attempts = 1
+	}
+	result, err := s.db.ExecContext(ctx, "update message set state = ?, attempts = attempts + ?, last_modified = ?, next_attempt = ? "+
+		"where id = ? and state = ?", to, attempts, time.Now(), next, id, from)
+	if err := s.checkUpdated(ctx, id, result, err); err != nil {
+		return nil, err
+	}
+	return s.GetMessage(ctx, id)
+}
+
+func (s *MessageStore) Postpone(ctx context.Context, id string, state MessageState, next time.Time) error {
+	result, err := s.db.ExecContext(ctx, "update message set next_attempt = ? where id = ? and state = ?", next, id, state)
+	return s.checkUpdated(ctx, id, result, err)
+}
+
+//checkUpdated在条件更新没有改到任何一行时区分消息不存在和状态已经变了
+func (s *MessageStore) checkUpdated(ctx context.Context, id string, result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := s.GetMessage(ctx, id); err != nil {
+			return err
+		}
+		return ErrStateChanged
+	}
+	return nil
+}
+
+func (s *MessageStore) Delete(ctx context.Context, id string, state MessageState) error {
+	result, err := s.db.ExecContext(ctx, "delete from message where id = ? and state = ?", id, state)
+	return s.checkUpdated(ctx, id, result, err)
+}
+
+func (s *MessageStore) GetMessage(ctx context.Context, id string) (*Message, error) {
+	m, err := scanMessage(s.db.QueryRowContext(ctx, "select "+messageColumns+" from message where id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	return m, err
+}
+
+func (s *MessageStore) GetMessagesInState(ctx context.Context, state MessageState, due time.Time, limit int) ([]*Message, error) {
+	results, err := s.db.QueryContext(ctx, "select "+messageColumns+" from message where state = ? and next_attempt <= ? "+
+		"order by next_attempt limit ?", state, due, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	list := make([]*Message, 0)
+	for results.Next() {
+		m, err := scanMessage(results)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, results.Err()
+}
+
+//MemoryStore把消息放在内存里，进程退出就没有了，用于测试和单机场景
+type MemoryStore struct {
+	mu       sync.Mutex
+	messages map[string]*Message
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{messages: map[string]*Message{}}
+}
+
+func (s *MemoryStore) Insert(ctx context.Context, m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.messages[m.ID]; ok {
+		return errors.New("duplicate message id " + m.ID)
+	}
+	cp := *m
+	s.messages[m.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) UpdateState(ctx context.Context, id string, from, to MessageState, next time.Time) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.messages[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	if m.State != from {
+		return nil, ErrStateChanged
+	}
+	m.State, m.LastModified, m.NextAttempt = to, time.Now(), next
+	if to == Sent {
+		m.Attempts++
+	}
+	cp := *m
+	return &cp, nil
+}
+
+func (s *MemoryStore) Postpone(ctx context.Context, id string, state MessageState, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.messages[id]
+	if !ok {
+		return ErrMessageNotFound
+	}
+	if m.State != state {
+		return ErrStateChanged
+	}
+	m.NextAttempt = next
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string, state MessageState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.messages[id]
+	if !ok {
+		return ErrMessageNotFound
+	}
+	if m.State != state {
+		return ErrStateChanged
+	}
+	delete(s.messages, id)
+	return nil
+}
+
+func (s *MemoryStore) GetMessage(ctx context.Context, id string) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.messages[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	cp := *m
+	return &cp, nil
+}
+
+func (s *MemoryStore) GetMessagesInState(ctx context.Context, state MessageState, due time.Time, limit int) ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Message, 0)
+	for _, m := range s.messages {
+		if m.State == state && !m.NextAttempt.After(due) {
+			cp := *m
+			list = append(list, &cp)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NextAttempt.Before(list[j].NextAttempt) })
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/mq/broker"
+)
+
+var ErrEmptyTopic = errors.New("topic is empty")
+
+//StateError表示消息当前的状态不允许这个操作，比如取消已经发送的消息
+type StateError struct {
+	ID    string
+	Op    string
+	State MessageState
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("cannot %s message %s in state %s", e.Op, e.ID, e.State)
+}
+
+//Timeouts决定消息恢复什么时候处理一条消息
+type Timeouts struct {
+	//prepared超过这个时间还没有确认或取消就回查，回查没有结果时再等这么久
+	Prepare time.Duration
+	//第n次投递之后等Send*2^(n-1)还没有被消费就重新投递，最多等MaxBackoff
+	Send       time.Duration
+	MaxBackoff time.Duration
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{Prepare: 2 * time.Minute, Send: 2 * time.Minute, MaxBackoff: time.Hour}
+}
+
+//backoff是第attempts次投递之后到下一次投递的等待时间
+func (t Timeouts) backoff(attempts int) time.Duration {
+	d := t.Send
+	for i := 1; i < attempts && d < t.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > t.MaxBackoff {
+		d = t.MaxBackoff
+	}
+	return d
+}
+
+type Service struct {
+	Ms       MessageHandler
+	Broker   broker.Broker
+	Timeouts Timeouts
+}
+
+func NewService(ms MessageHandler, b broker.Broker) *Service {
+	return &Service{Ms: ms, Broker: b, Timeouts: DefaultTimeouts()}
+}
+
+//Prepare对应流程的第1、2步：存储预发送的消息，生产者拿到id之后再执行本地业务
+func (s *Service) Prepare(ctx context.Context, topic, producer string, body []byte) (*Message, error) {
+	if topic == "" {
+		return nil, ErrEmptyTopic
+	}
+	now := time.Now()
+	m := &Message{
+		ID:           uuid.New().String(),
+		Topic:        topic,
+		Body:         body,
+		Producer:     producer,
+		State:        Prepared,
+		CreatedAt:    now,
+		LastModified: now,
+		NextAttempt:  now.Add(s.Timeouts.Prepare),
+	}
+	if err := s.Ms.Insert(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//Confirm对应第5、6步：先把消息改为sent再投递，投递失败的消息由消息恢复重新投递，
+//所以投递失败也返回成功。重复确认会再投递一次
+func (s *Service) Confirm(ctx context.Context, id string) (*Message, error) {
+	return s.transition(ctx, id, func(m *Message) (*Message, error) {
+		switch m.State {
+		case Prepared, Sent:
+			return s.send(ctx, m)
+		case Consumed:
+			return m, nil
+		}
+		return nil, &StateError{ID: id, Op: "confirm", State: m.State}
+	})
+}
+
+//ConfirmPrepared是消息恢复用的确认，m是消息恢复读到的消息：只有消息还停在prepared时才改为sent并投递，
+//扫描之后已经被生产者确认或者取消的消息返回ErrStateChanged或者ErrMessageNotFound，不会再投递一次
+func (s *Service) ConfirmPrepared(ctx context.Context, m *Message) (*Message, error) {
+	if m.State != Prepared {
+		return nil, &StateError{ID: m.ID, Op: "confirm", State: m.State}
+	}
+	return s.send(ctx, m)
+}
+
+//Cancel删除生产者本地业务失败的预发送消息，消息不存在时认为已经取消过了
+func (s *Service) Cancel(ctx context.Context, id string) error {
+	_, err := s.transition(ctx, id, func(m *Message) (*Message, error) {
+		if m.State != Prepared {
+			return nil, &StateError{ID: id, Op: "cancel", State: m.State}
+		}
+		return nil, s.Ms.Delete(ctx, id, Prepared)
+	})
+	if err == ErrMessageNotFound {
+		return nil
+	}
+	return err
+}
+
+//Consumed对应第9步：消费者处理完之后确认，dead的消息被人工重新投递后也可以确认
+func (s *Service) Consumed(ctx context.Context, id string) (*Message, error) {
+	return s.transition(ctx, id, func(m *Message) (*Message, error) {
+		switch m.State {
+		case Sent, Dead:
+			return s.Ms.UpdateState(ctx, id, m.State, Consumed, time.Now())
+		case Consumed:
+			return m, nil
+		}
+		return nil, &StateError{ID: id, Op: "consume", State: m.State}
+	})
+}
+
+//Redeliver重新投递已经发送但还没有被消费的消息，投递次数加一，m是消息恢复读到的消息
+func (s *Service) Redeliver(ctx context.Context, m *Message) (*Message, error) {
+	if m.State != Sent {
+		return nil, &StateError{ID: m.ID, Op: "redeliver", State: m.State}
+	}
+	return s.send(ctx, m)
+}
+
+//Kill把投递次数用完还没有被消费的消息改为dead，不再自动投递
+func (s *Service) Kill(ctx context.Context, id string) (*Message, error) {
+	return s.Ms.UpdateState(ctx, id, Sent, Dead, time.Now())
+}
+
+//Postpone把回查没有结果的prepared消息推迟Prepare再回查
+func (s *Service) Postpone(ctx context.Context, id string) error {
+	return s.Ms.Postpone(ctx, id, Prepared, time.Now().Add(s.Timeouts.Prepare))
+}
+
+//Pending返回已经到期、还停在state的消息，给消息恢复用
+func (s *Service) Pending(ctx context.Context, state MessageState, limit int) ([]*Message, error) {
+	return s.Ms.GetMessagesInState(ctx, state, time.Now(), limit)
+}
+
+//send把消息改为sent并投递，按这是第几次投递安排下一次重新投递的时间
+func (s *Service) send(ctx context.Context, m *Message) (*Message, error) {
+	next := time.Now().Add(s.Timeouts.backoff(m.Attempts + 1))
+	sent, err := s.Ms.UpdateState(ctx, m.ID, m.State, Sent, next)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, sent)
+	return sent, nil
+}
+
+func (s *Service) GetMessage(ctx context.Context, id string) (*Message, error) {
+	return s.Ms.GetMessage(ctx, id)
+}
+
+//transition按消息当前的状态执行fn，并发的请求改了状态时重新读取再执行
+func (s *Service) transition(ctx context.Context, id string, fn func(m *Message) (*Message, error)) (*Message, error) {
+	for {
+		m, err := s.Ms.GetMessage(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		next, err := fn(m)
+		if err != ErrStateChanged {
+			return next, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *Service) publish(ctx context.Context, m *Message) {
+	if err := s.Broker.Publish(ctx, broker.Message{ID: m.ID, Topic: m.Topic, Body: m.Body}); err != nil {
+		log.Warnf("publish message %s failed after %d attempts: %v", m.ID, m.Attempts, err)
+	}
+}
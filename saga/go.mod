@@ -0,0 +1,8 @@
+module github.com/sysphusking/dsts/saga
+
+go 1.13
+
+require (
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/google/uuid v1.1.2
+)
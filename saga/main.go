@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/sysphusking/dsts/saga/service"
+)
+
+type order struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+}
+
+//下单的saga：扣库存、扣款、发货，金额超过余额时扣款失败，前面的步骤倒序补偿
+func main() {
+	dsn := flag.String("dsn", "", "mysql dsn, e.g. root:password@tcp(localhost)/test?parseTime=true; empty keeps state in memory")
+	amount := flag.Int("amount", 120, "order amount, more than 100 makes the payment fail")
+	flag.Parse()
+
+	var store service.SagaHandler = service.NewMemoryStore()
+	if *dsn != "" {
+		db, err := sql.Open("mysql", *dsn)
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+		store = service.NewSagaStore(db)
+	}
+
+	balance := 100
+	srv := service.NewService(store)
+	srv.RetryInterval = 10 * time.Millisecond
+	err := srv.Register(service.Definition{
+		Name: "order",
+		Steps: []service.Step{
+			{
+				Name:       "reserve",
+				Action:     logStep("reserve inventory"),
+				Compensate: logStep("release inventory"),
+			},
+			{
+				Name: "pay",
+				Action: func(ctx context.Context, id string, data []byte) error {
+					var o order
+					if err := json.Unmarshal(data, &o); err != nil {
+						return err
+					}
+					if o.Amount > balance {
+						return errors.New("insufficient balance")
+					}
+					balance -= o.Amount
+					fmt.Printf("%s: charge %d\n", id, o.Amount)
+					return nil
+				},
+				Compensate: logStep("refund"),
+			},
+			{
+				Name:       "ship",
+				Action:     logStep("ship"),
+				Compensate: logStep("cancel shipment"),
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := context.Background()
+	//先把上次崩溃时没有结束的saga处理完
+	if err := srv.RecoverSagas(ctx, time.Now()); err != nil {
+		log.Println("recover sagas: ", err)
+	}
+
+	data, _ := json.Marshal(order{ID: "order1", Amount: *amount})
+	resp, err := srv.StartSaga(ctx, "order", data)
+	if resp != nil {
+		fmt.Printf("saga %s %s\n", resp.SagaID, resp.State)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func logStep(name string) func(ctx context.Context, id string, data []byte) error {
+	return func(ctx context.Context, id string, data []byte) error {
+		fmt.Printf("%s: %s\n", id, name)
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Running SagaState = iota
+	Compensating
+	Done
+	Compensated
+)
+
+type SagaState int
+
+func (s SagaState) String() string {
+	switch s {
+	case Running:
+		return "running"
+	case Compensating:
+		return "compensating"
+	case Done:
+		return "done"
+	case Compensated:
+		return "compensated"
+	}
+	return "unknown"
+}
+
+var ErrSagaNotFound = errors.New("saga not found")
+
+type SagaHandler interface {
+	Insert(ctx context.Context, name string, data []byte) (string, error)
+	Update(ctx context.Context, id string, state SagaState, step int) (*Saga, error)
+	GetSaga(ctx context.Context, id string) (*Saga, error)
+	GetAllSagasInState(ctx context.Context, state SagaState) ([]*Saga, error)
+}
+
+//Step的含义跟着状态变：running时是已经执行完的步骤数，compensating时是还没有补偿的步骤数
+type Saga struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	State        SagaState `json:"state"`
+	Step         int       `json:"step"`
+	Data         []byte    `json:"data"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+func (s Saga) TableName() string {
+	return "saga"
+}
+
+type SagaStore struct {
+	db *sql.DB
+}
+
+func NewSagaStore(db *sql.DB) *SagaStore {
+	return &SagaStore{db: db}
+}
+
+func (s *SagaStore) Insert(ctx context.Context, name string, data []byte) (string, error) {
+	id := uuid.New().String()
+	if _, err := s.db.ExecContext(ctx, "insert into saga(id, name, state, step, data, last_modified) values (?,?,?,?,?,?)",
+		id, name, Running, 0, data, time.Now()); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (s *SagaStore) Update(ctx context.Context, id string, state SagaState, step int) (*Saga, error) {
+	result, err := s.db.ExecContext(ctx, "update saga set state = ?, step = ?, last_modified = ? where id = ?",
+		state, step, time.Now(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, ErrSagaNotFound
+	}
+	return s.GetSaga(ctx, id)
+}
+
+func (s *SagaStore) GetSaga(ctx context.Context, id string) (*Saga, error) {
+	sg := &Saga{}
+	err := s.db.QueryRowContext(ctx, "select id, name, state, step, data, last_modified from saga where id = ?", id).
+		Scan(&sg.ID, &sg.Name, &sg.State, &sg.Step, &sg.Data, &sg.LastModified)
+	if err == sql.ErrNoRows {
+		return nil, ErrSagaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sg, nil
+}
+
+func (s *SagaStore) GetAllSagasInState(ctx context.Context, state SagaState) ([]*Saga, error) {
+	results, err := s.db.QueryContext(ctx, "select id, name, state, step, data, last_modified from saga where state = ?", state)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	list := make([]*Saga, 0)
+	for results.Next() {
+		var sg Saga
+		if err := results.Scan(&sg.ID, &sg.Name, &sg.State, &sg.Step, &sg.Data, &sg.LastModified); err != nil {
+			return nil, err
+		}
+		list = append(list, &sg)
+	}
+	return list, results.Err()
+}
+
+//MemoryStore把状态放在内存里，进程退出就没有了，用于示例和单机场景
+type MemoryStore struct {
+	mu    sync.Mutex
+	sagas map[string]*Saga
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sagas: map[string]*Saga{}}
+}
+
+func (m *MemoryStore) Insert(ctx context.Context, name string, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := uuid.New().String()
+	m.sagas[id] = &Saga{ID: id, Name: name, State: Running, Data: data, LastModified: time.Now()}
+	return id, nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, id string, state SagaState, step int) (*Saga, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sg, ok := m.sagas[id]
+	if !ok {
+		return nil, ErrSagaNotFound
+	}
+	sg.State, sg.Step, sg.LastModified = state, step, time.Now()
+	cp := *sg
+	return &cp, nil
+}
+
+func (m *MemoryStore) GetSaga(ctx context.Context, id string) (*Saga, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sg, ok := m.sagas[id]
+	if !ok {
+		return nil, ErrSagaNotFound
+	}
+	cp := *sg
+	return &cp, nil
+}
+
+func (m *MemoryStore) GetAllSagasInState(ctx context.Context, state SagaState) ([]*Saga, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Saga, 0)
+	for _, sg := range m.sagas {
+		if sg.State == state {
+			cp := *sg
+			list = append(list, &cp)
+		}
+	}
+	return list, nil
+}
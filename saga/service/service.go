@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+//Action和Compensate拿到的id是saga的id，可以用来做幂等。
+//崩溃恢复时最后一个没有落盘的步骤会重新执行，所以两者都必须是幂等的；
+//失败的步骤也会被补偿，Compensate要能处理动作没有执行过的情况
+type Step struct {
+	Name       string
+	Action     func(ctx context.Context, id string, data []byte) error
+	Compensate func(ctx context.Context, id string, data []byte) error
+}
+
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+type Response struct {
+	SagaID       string
+	State        SagaState
+	LastModified int64
+}
+
+//StepError是导致saga回滚的步骤错误
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("saga step %s failed: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+type Service struct {
+	Ss SagaHandler
+	//每个动作和补偿失败后的重试次数和间隔，补偿重试完还失败时saga停在compensating，等下次恢复
+	Retries       int
+	RetryInterval time.Duration
+
+	definitions map[string]Definition
+}
+
+func NewService(ss SagaHandler) *Service {
+	return &Service{Ss: ss, Retries: 3, RetryInterval: 100 * time.Millisecond, definitions: map[string]Definition{}}
+}
+
+//Register要在StartSaga和RecoverSagas之前调用，恢复时按名字找回步骤
+func (s *Service) Register(def Definition) error {
+	if def.Name == "" || len(def.Steps) == 0 {
+		return errors.New("saga definition needs a name and at least one step")
+	}
+	if _, ok := s.definitions[def.Name]; ok {
+		return fmt.Errorf("saga %s already registered", def.Name)
+	}
+	for _, step := range def.Steps {
+		if step.Action == nil || step.Compensate == nil {
+			return fmt.Errorf("saga %s: step %s needs an action and a compensation", def.Name, step.Name)
+		}
+	}
+	s.definitions[def.Name] = def
+	return nil
+}
+
+//StartSaga按顺序执行各个步骤，每一步成功后落盘。某一步失败时倒序补偿，返回*StepError
+func (s *Service) StartSaga(ctx context.Context, name string, data []byte) (*Response, error) {
+	def, ok := s.definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("saga %s not registered", name)
+	}
+	id, err := s.Ss.Insert(ctx, name, data)
+	if err != nil {
+		//失败了直接返回，还没有执行任何步骤
+		return nil, err
+	}
+	sg, err := s.run(ctx, def, &Saga{ID: id, Name: name, State: Running, Data: data})
+	if sg == nil {
+		return nil, err
+	}
+	return &Response{SagaID: id, State: sg.State, LastModified: sg.LastModified.Unix()}, err
+}
+
+func (s *Service) GetSaga(ctx context.Context, id string) (*Saga, error) {
+	return s.Ss.GetSaga(ctx, id)
+}
+
+//run从落盘的位置继续执行，返回最后落盘的状态
+func (s *Service) run(ctx context.Context, def Definition, sg *Saga) (*Saga, error) {
+	if sg.State == Running {
+		for sg.Step < len(def.Steps) {
+			step := def.Steps[sg.Step]
+			if err := s.retry(ctx, func() error { return step.Action(ctx, sg.ID, sg.Data) }); err != nil {
+				stepErr := &StepError{Step: step.Name, Err: err}
+				log.Printf("saga %s(%s): %v, compensating", def.Name, sg.ID, stepErr)
+				//失败的步骤可能执行了一部分，也要补偿
+				next, err := s.Ss.Update(ctx, sg.ID, Compensating, sg.Step+1)
+				if err != nil {
+					return sg, err
+				}
+				sg, err = s.compensate(ctx, def, next)
+				if err != nil {
+					return sg, err
+				}
+				return sg, stepErr
+			}
+			next, err := s.Ss.Update(ctx, sg.ID, Running, sg.Step+1)
+			if err != nil {
+				return sg, err
+			}
+			sg = next
+		}
+		return s.Ss.Update(ctx, sg.ID, Done, sg.Step)
+	}
+	if sg.State == Compensating {
+		return s.compensate(ctx, def, sg)
+	}
+	return sg, nil
+}
+
+func (s *Service) compensate(ctx context.Context, def Definition, sg *Saga) (*Saga, error) {
+	for sg.Step > 0 {
+		step := def.Steps[sg.Step-1]
+		if err := s.retry(ctx, func() error { return step.Compensate(ctx, sg.ID, sg.Data) }); err != nil {
+			return sg, fmt.Errorf("saga %s(%s): compensation of step %s failed: %v", def.Name, sg.ID, step.Name, err)
+		}
+		next, err := s.Ss.Update(ctx, sg.ID, Compensating, sg.Step-1)
+		if err != nil {
+			return sg, err
+		}
+		sg = next
+	}
+	return s.Ss.Update(ctx, sg.ID, Compensated, 0)
+}
+
+func (s *Service) retry(ctx context.Context, f func() error) error {
+	err := f()
+	for i := 0; i < s.Retries && err != nil; i++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(s.RetryInterval):
+		}
+		err = f()
+	}
+	return err
+}
+
+//RecoverSagas继续执行崩溃时没有结束的saga，recoverTime之后修改过的saga可能还在执行，不会被恢复。
+//返回遇到的第一个错误，其他saga照常恢复
+func (s *Service) RecoverSagas(ctx context.Context, recoverTime time.Time) error {
+	var first error
+	for _, state := range []SagaState{Compensating, Running} {
+		list, err := s.Ss.GetAllSagasInState(ctx, state)
+		if err != nil {
+			return err
+		}
+		for _, sg := range list {
+			if !recoverTime.After(sg.LastModified) {
+				continue
+			}
+			def, ok := s.definitions[sg.Name]
+			if !ok {
+				err = fmt.Errorf("saga %s(%s) not registered", sg.Name, sg.ID)
+			} else {
+				_, err = s.run(ctx, def, sg)
+			}
+			if err != nil {
+				var stepErr *StepError
+				//步骤失败已经补偿完了，算恢复成功
+				if errors.As(err, &stepErr) {
+					continue
+				}
+				log.Printf("recover saga %s: %v", sg.ID, err)
+				if first == nil {
+					first = err
+				}
+			}
+		}
+	}
+	return first
+}
@@ -2,36 +2,35 @@ package cache
 
 import "sync"
 
-type msg struct {
-	Key   string
-	Value []byte
+//Msg是某个index上等待提交的写操作，Delete为true时表示删除Key，合并提交时一个index上会有多个Msg
+type Msg struct {
+	Key    string
+	Value  []byte
+	Delete bool
 }
 
 type ICache interface {
-	Set(index uint64, key string, value []byte)
-	Get(index uint64) (string, []byte, bool)
+	Set(index uint64, msgs ...Msg)
+	Get(index uint64) ([]Msg, bool)
 	Delete(index uint64)
 }
 
 type Cache struct {
-	store map[uint64]msg
+	store map[uint64][]Msg
 	mu    sync.RWMutex
 }
 
-func (c *Cache) Set(index uint64, key string, value []byte) {
+func (c *Cache) Set(index uint64, msgs ...Msg) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.store[index] = msg{
-		Key:   key,
-		Value: value,
-	}
+	c.store[index] = msgs
 }
 
-func (c *Cache) Get(index uint64) (string, []byte, bool) {
+func (c *Cache) Get(index uint64) ([]Msg, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	message, ok := c.store[index]
-	return message.Key, message.Value, ok
+	messages, ok := c.store[index]
+	return messages, ok
 }
 
 func (c *Cache) Delete(index uint64) {
@@ -41,6 +40,6 @@ func (c *Cache) Delete(index uint64) {
 }
 
 func New() *Cache {
-	hashtable := make(map[uint64]msg)
+	hashtable := make(map[uint64][]Msg)
 	return &Cache{store: hashtable}
 }
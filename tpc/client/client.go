@@ -2,6 +2,10 @@ package client
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync"
 
 	"github.com/pkg/errors"
 
@@ -12,20 +16,135 @@ import (
 )
 
 type CommitClient struct {
+	//第一个地址上的连接，Propose/Precommit/Commit这些协议消息直接发给它
 	Connection pb.CommitClient
+	addrs      []string
+	opts       options
+	conns      map[string]*grpc.ClientConn
+	//通过NodeInfo发现的coordinator，Put/Get/Scan/Watch都发给它
+	leader pb.CommitClient
+	mu     sync.Mutex
 }
 
-func New(addr string) (*CommitClient, error) {
+func New(addr string, opts ...Option) (*CommitClient, error) {
+	return NewCluster([]string{addr}, opts...)
+}
+
+//NewCluster连接多个节点，业务请求会发给通过NodeInfo发现的coordinator
+func NewCluster(addrs []string, opts ...Option) (*CommitClient, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no node address")
+	}
+	c := &CommitClient{
+		addrs: addrs,
+		opts:  defaultOptions(),
+		conns: map[string]*grpc.ClientConn{},
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	conn, err := c.dial(addrs[0])
+	if err != nil {
+		return nil, err
+	}
+	c.Connection = pb.NewCommitClient(conn)
+	return c, nil
+}
+
+//Addr返回第一个节点的地址，也就是协议消息发往的节点
+func (c *CommitClient) Addr() string {
+	return c.addrs[0]
+}
+
+func (c *CommitClient) dial(addr string) (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
 	conn, err := grpc.Dial(
 		addr,
 		grpc.WithInsecure(),
+		grpc.WithKeepaliveParams(c.opts.keepalive),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to connect")
 	}
-	return &CommitClient{
-		Connection: pb.NewCommitClient(conn),
-	}, nil
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+//依次询问每个节点，直到有节点告诉我们coordinator的地址，都不知道时退回到第一个节点
+func (c *CommitClient) discover(ctx context.Context) (pb.CommitClient, error) {
+	c.mu.Lock()
+	leader := c.leader
+	c.mu.Unlock()
+	if leader != nil {
+		return leader, nil
+	}
+
+	leader = c.Connection
+	for _, addr := range c.addrs {
+		conn, err := c.dial(addr)
+		if err != nil {
+			continue
+		}
+		info, err := pb.NewCommitClient(conn).NodeInfo(ctx, &empty.Empty{})
+		if err != nil || info.Coordinator == "" {
+			continue
+		}
+		if conn, err = c.dial(info.Coordinator); err == nil {
+			leader = pb.NewCommitClient(conn)
+			break
+		}
+	}
+	c.mu.Lock()
+	c.leader = leader
+	c.mu.Unlock()
+	return leader, nil
+}
+
+func (c *CommitClient) forget(leader pb.CommitClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leader == leader {
+		c.leader = nil
+	}
+}
+
+//invoke把请求发给coordinator，遇到可重试的错误时退避重试，连接不可用时重新发现coordinator
+func (c *CommitClient) invoke(ctx context.Context, call func(cli pb.CommitClient) error) error {
+	var err error
+	for attempt := 0; attempt < c.opts.maxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.opts.backoff(attempt-1)); err != nil {
+				return err
+			}
+		}
+		var leader pb.CommitClient
+		if leader, err = c.discover(ctx); err != nil {
+			continue
+		}
+		if err = call(leader); err == nil || !retryable(err) {
+			return err
+		}
+		c.forget(leader)
+	}
+	return err
+}
+
+func (c *CommitClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for addr, conn := range c.conns {
+		if e := conn.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(c.conns, addr)
+	}
+	c.leader = nil
+	return err
 }
 
 func (c *CommitClient) Propose(ctx context.Context, in *pb.ProposeRequest) (*pb.Response, error) {
@@ -40,17 +159,121 @@ func (c *CommitClient) Commit(ctx context.Context, in *pb.CommitRequest) (*pb.Re
 	return c.Connection.Commit(ctx, in)
 }
 
+//Decision向coordinator询问presumed变种中某个事务的结果
+func (c *CommitClient) Decision(ctx context.Context, txid string) (*pb.DecisionResponse, error) {
+	return c.Connection.Decision(ctx, &pb.DecisionRequest{Txid: txid})
+}
+
+//Put会重试，没有指定request id时自动生成一个，保证重试不会重复写入
 func (c *CommitClient) Put(ctx context.Context, key string, value []byte) (*pb.Response, error) {
-	return c.Connection.Put(ctx, &pb.Entry{
+	return c.put(ctx, &pb.Entry{
 		Key:   key,
 		Value: value,
 	})
 }
 
-func (c *CommitClient) Get(ctx context.Context, key string) (*pb.Value, error) {
-	return c.Connection.Get(ctx, &pb.Msg{Key: key})
+//PutWithRequestID超时或失败后可以用同一个requestID重试，coordinator会返回第一次的结果而不会重复写入
+func (c *CommitClient) PutWithRequestID(ctx context.Context, requestID, key string, value []byte) (*pb.Response, error) {
+	return c.put(ctx, &pb.Entry{
+		Key:       key,
+		Value:     value,
+		RequestId: requestID,
+	})
+}
+
+func (c *CommitClient) Delete(ctx context.Context, key string) (*pb.Response, error) {
+	return c.put(ctx, &pb.Entry{
+		Key:    key,
+		Delete: true,
+	})
+}
+
+func (c *CommitClient) put(ctx context.Context, entry *pb.Entry) (resp *pb.Response, err error) {
+	if entry.RequestId == "" {
+		entry.RequestId = newRequestID()
+	}
+	err = c.invoke(ctx, func(cli pb.CommitClient) error {
+		resp, err = cli.Put(ctx, entry)
+		return err
+	})
+	return
+}
+
+func (c *CommitClient) Get(ctx context.Context, key string) (value *pb.Value, err error) {
+	err = c.invoke(ctx, func(cli pb.CommitClient) error {
+		value, err = cli.Get(ctx, &pb.Msg{Key: key})
+		return err
+	})
+	return
+}
+
+func (c *CommitClient) NodeInfo(ctx context.Context) (info *pb.Info, err error) {
+	err = c.invoke(ctx, func(pb.CommitClient) error {
+		info, err = c.Connection.NodeInfo(ctx, &empty.Empty{})
+		return err
+	})
+	return
+}
+
+//Scan读取一页数据，返回的token不为空时表示还有下一页，把它放到下一次请求的Token里即可
+func (c *CommitClient) Scan(ctx context.Context, in *pb.ScanRequest) (kvs []*pb.ScanResponse, token string, err error) {
+	err = c.invoke(ctx, func(cli pb.CommitClient) error {
+		kvs, token, err = scan(ctx, cli, in)
+		return err
+	})
+	return
+}
+
+func scan(ctx context.Context, cli pb.CommitClient, in *pb.ScanRequest) ([]*pb.ScanResponse, string, error) {
+	stream, err := cli.Scan(ctx, in)
+	if err != nil {
+		return nil, "", err
+	}
+	var (
+		kvs   []*pb.ScanResponse
+		token string
+	)
+	for {
+		kv, err := stream.Recv()
+		if err == io.EOF {
+			return kvs, token, nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		kvs = append(kvs, kv)
+		token = kv.Token
+	}
+}
+
+//Watch返回的stream会一直推送已提交的修改，断开后用最后收到事件的Index+1作为fromHeight重新Watch
+func (c *CommitClient) Watch(ctx context.Context, prefix string, fromHeight uint64) (stream pb.Commit_WatchClient, err error) {
+	err = c.invoke(ctx, func(cli pb.CommitClient) error {
+		stream, err = cli.Watch(ctx, &pb.WatchRequest{Prefix: prefix, FromHeight: fromHeight})
+		return err
+	})
+	return
+}
+
+//WatchRange推送[fromHeight, toHeight)之间已提交的修改，推送完后stream返回io.EOF
+func (c *CommitClient) WatchRange(ctx context.Context, prefix string, fromHeight, toHeight uint64) (stream pb.Commit_WatchClient, err error) {
+	err = c.invoke(ctx, func(cli pb.CommitClient) error {
+		stream, err = cli.Watch(ctx, &pb.WatchRequest{Prefix: prefix, FromHeight: fromHeight, ToHeight: toHeight})
+		return err
+	})
+	return
+}
+
+func (c *CommitClient) Snapshot(ctx context.Context) (stream pb.Commit_SnapshotClient, err error) {
+	err = c.invoke(ctx, func(cli pb.CommitClient) error {
+		stream, err = cli.Snapshot(ctx, &empty.Empty{})
+		return err
+	})
+	return
 }
 
-func (c *CommitClient) NodeInfo(ctx context.Context) (*pb.Info, error) {
-	return c.Connection.NodeInfo(ctx, &empty.Empty{})
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
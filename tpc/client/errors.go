@@ -0,0 +1,39 @@
+package client
+
+import (
+	"google.golang.org/grpc/status"
+
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//Votes从Put返回的错误中取出follower的投票结果，可以看到是哪个节点因为什么拒绝了这次写入
+func Votes(err error) []*pb.Response {
+	var votes []*pb.Response
+	for _, detail := range status.Convert(err).Details() {
+		if vote, ok := detail.(*pb.Response); ok {
+			votes = append(votes, vote)
+		}
+	}
+	return votes
+}
+
+//Reason返回第一个拒绝的原因，错误不是follower的拒绝造成时返回NONE
+func Reason(err error) pb.Reason {
+	for _, vote := range Votes(err) {
+		if vote.Reason != pb.Reason_NONE {
+			return vote.Reason
+		}
+	}
+	return pb.Reason_NONE
+}
+
+//RolledBack判断写入失败时是否确定已经回滚：coordinator在做出提交决定之前失败会在details里带上ABORTED，
+//没有带的错误（超时、连接断开、做出决定之后的失败）都可能已经写入
+func RolledBack(err error) bool {
+	for _, detail := range status.Convert(err).Details() {
+		if decision, ok := detail.(*pb.DecisionResponse); ok && decision.Outcome == pb.Outcome_ABORTED {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//Journal返回第一个节点上某个高度的协议事件
+func (c *CommitClient) Journal(ctx context.Context, index uint64) ([]*pb.JournalEvent, error) {
+	resp, err := c.Connection.Journal(ctx, &pb.JournalRequest{Index: index})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+//ClusterJournal从每个节点取回某个高度的协议事件，按时间合并成这个事务在整个集群里的经过，
+//取不到的节点会被跳过，所有节点都失败时返回第一个错误
+func ClusterJournal(ctx context.Context, nodes []*CommitClient, index uint64) ([]*pb.JournalEvent, error) {
+	var (
+		events   []*pb.JournalEvent
+		firstErr error
+		ok       bool
+	)
+	for _, node := range nodes {
+		e, err := node.Journal(ctx, index)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to get journal from %s: %v", node.Addr(), err)
+			}
+			continue
+		}
+		ok = true
+		events = append(events, e...)
+	}
+	if !ok && firstErr != nil {
+		return nil, firstErr
+	}
+	//不同节点的时钟可能有偏差，只能大致反映先后顺序
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+	return events, nil
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+type options struct {
+	//包括第一次在内最多尝试的次数
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	keepalive   keepalive.ClientParameters
+}
+
+type Option func(*options)
+
+func defaultOptions() options {
+	return options{
+		maxAttempts: 5,
+		baseBackoff: 50 * time.Millisecond,
+		maxBackoff:  2 * time.Second,
+		keepalive: keepalive.ClientParameters{
+			Time:                10 * time.Second,
+			Timeout:             3 * time.Second,
+			PermitWithoutStream: true,
+		},
+	}
+}
+
+//WithRetry设置最多尝试的次数和指数退避的初始、最大间隔，maxAttempts为1时不重试
+func WithRetry(maxAttempts int, base, max time.Duration) Option {
+	return func(o *options) {
+		o.maxAttempts = maxAttempts
+		o.baseBackoff = base
+		o.maxBackoff = max
+	}
+}
+
+//WithKeepalive设置连接空闲多久后发送ping，以及等待ping响应的超时时间
+func WithKeepalive(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.keepalive.Time = interval
+		o.keepalive.Timeout = timeout
+	}
+}
+
+//这些错误码说明请求可能没有被处理，或者可以安全地重新发送
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+//第attempt次重试前等待的时间，指数增长并加上full jitter
+func (o *options) backoff(attempt int) time.Duration {
+	d := o.baseBackoff << uint(attempt)
+	if d <= 0 || d > o.maxBackoff {
+		d = o.maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//Tx是由应用指定参与者的事务，参与者可以是任意运行tpc的节点
+type Tx struct {
+	ID string
+	c  *CommitClient
+}
+
+//Begin在coordinator上开始一个事务
+func (c *CommitClient) Begin(ctx context.Context) (*Tx, error) {
+	var tx *pb.Tx
+	err := c.invoke(ctx, func(cli pb.CommitClient) (err error) {
+		tx, err = cli.Begin(ctx, &empty.Empty{})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{ID: tx.Txid, c: c}, nil
+}
+
+//AddParticipant登记参与者addr以及它要写入的数据，同一个参与者可以登记多次，同一个key以最后一次为准
+func (t *Tx) AddParticipant(ctx context.Context, addr string, entries ...*pb.Entry) error {
+	return t.c.invoke(ctx, func(cli pb.CommitClient) error {
+		_, err := cli.AddParticipant(ctx, &pb.ParticipantRequest{Txid: t.ID, Addr: addr, Entries: entries})
+		return err
+	})
+}
+
+//Put登记在参与者addr上写入key
+func (t *Tx) Put(ctx context.Context, addr, key string, value []byte) error {
+	return t.AddParticipant(ctx, addr, &pb.Entry{Key: key, Value: value})
+}
+
+//Commit只在登记过的参与者之间提交，返回的错误和Put一样带着参与者的投票结果
+func (t *Tx) Commit(ctx context.Context) (resp *pb.Response, err error) {
+	err = t.c.invoke(ctx, func(cli pb.CommitClient) error {
+		resp, err = cli.CommitTx(ctx, &pb.Tx{Txid: t.ID})
+		return err
+	})
+	return
+}
+
+func (t *Tx) Rollback(ctx context.Context) error {
+	return t.c.invoke(ctx, func(cli pb.CommitClient) error {
+		_, err := cli.RollbackTx(ctx, &pb.Tx{Txid: t.ID})
+		return err
+	})
+}
@@ -18,8 +18,48 @@ type Config struct {
 	Whitelist   []string
 	CommitType  string
 	Timeout     uint64
+	//各阶段的超时时间(ms)，为0时使用Timeout
+	ProposeTimeout   uint64
+	PrecommitTimeout uint64
+	CommitTimeout    uint64
 	//DBSchema    string
 	Hooks string
+	//快照文件目录，SnapshotInterval(ms)为0时不做定时快照和日志压缩
+	SnapshotDir      string
+	SnapshotInterval uint64
+	//运维用：从快照文件恢复数据或者把当前数据备份成快照文件，执行完后直接退出
+	Restore string
+	Backup  string
+	//coordinator按request id去重时最多记住的请求数
+	DedupSize int
+	//合并提交：一轮最多合并的Put个数，以及每一轮开始前最多等待凑批的时间(ms)
+	BatchSize   int
+	BatchLinger uint64
+	//指标的http地址，为空时不开启
+	MetricsAddr string
+	//http/json网关的地址，为空时不开启
+	HttpAddr string
+	//presumed-abort/presumed-commit的协议日志目录
+	WalDir string
+	//参与者上key锁的冲突处理方式(wait-die或no-wait)，以及等待锁的超时时间(ms)，为0时使用Timeout
+	LockPolicy  string
+	LockTimeout uint64
+	//参与者的存储后端：gorm直接写入，xa在投票之前XA PREPARE
+	Backend string
+	//Stop时等待进行中的轮次和后台提交完成的最长时间(ms)
+	ShutdownTimeout uint64
+	//分片，只在配置文件里设置，为空时每个follower都保存所有的key
+	Shards    []Shard
+	HashSlots int
+}
+
+//Shard把一段key范围[Start, End)或者一些hash槽（形如"0-511"）分给一组follower，End为空表示没有上限
+type Shard struct {
+	Name      string
+	Start     string
+	End       string
+	Slots     []string
+	Followers []string
 }
 
 type followers []string
@@ -59,9 +99,26 @@ func Get() *Config {
 	role := flag.String("role", "follower", "role (coordinator of follower)")
 	nodeaddr := flag.String("nodeaddr", "localhost:3050", "node address")
 	coordinator := flag.String("coordinator", "", "coordinator address")
-	commitType := flag.String("committype", "two-phase", "two-phase or three-phase commit mode")
-	timeout := flag.Uint64("timeout", 1000, "ms, timeout after which the message is considered unacknowledged (only for three-phase mode, because two-phase is blocking by design)")
+	commitType := flag.String("committype", "two-phase", "two-phase, three-phase, presumed-abort or presumed-commit commit mode")
+	timeout := flag.Uint64("timeout", 1000, "ms, default timeout after which a phase message is considered unacknowledged, also the follower's autocommit timeout in three-phase mode")
+	proposeTimeout := flag.Uint64("proposetimeout", 0, "ms, propose timeout, the round is aborted when it fires (0 uses timeout)")
+	precommitTimeout := flag.Uint64("precommittimeout", 0, "ms, precommit timeout, the round is aborted when it fires (0 uses timeout)")
+	commitTimeout := flag.Uint64("committimeout", 0, "ms, commit timeout, the commit is retried in background when it fires (0 uses timeout)")
 	hooks := flag.String("hooks", "hooks/src/hooks.go", "path to hooks file on filesystem")
+	snapshotDir := flag.String("snapshotdir", "snapshots", "directory where snapshot files are kept")
+	snapshotInterval := flag.Uint64("snapshotinterval", 0, "ms, interval between snapshots, older committed entries are compacted after each snapshot (0 disables)")
+	restore := flag.String("restore", "", "restore the kv store from a snapshot file and exit")
+	backup := flag.String("backup", "", "write a snapshot of the kv store to the file and exit")
+	dedupSize := flag.Int("dedupsize", 10000, "number of request ids the coordinator remembers to deduplicate retried puts")
+	batchSize := flag.Int("batchsize", 64, "max number of concurrent puts committed together in one round")
+	batchLinger := flag.Uint64("batchlinger", 0, "ms, how long the coordinator waits for more puts before starting a round")
+	httpAddr := flag.String("httpaddr", "", "address of the http/json gateway serving /kv/{key} and /node (empty disables)")
+	metricsAddr := flag.String("metricsaddr", "", "address to serve metrics on /debug/vars (empty disables)")
+	lockPolicy := flag.String("lockpolicy", "wait-die", "how participants handle conflicting key locks: wait-die or no-wait")
+	lockTimeout := flag.Uint64("locktimeout", 0, "ms, how long a transaction waits for a key lock before giving up (0 uses timeout)")
+	backend := flag.String("backend", "gorm", "participant storage backend: gorm, or xa to prepare writes with MySQL XA before voting")
+	shutdownTimeout := flag.Uint64("shutdowntimeout", 10000, "ms, how long stop waits for rounds in progress and pending commits before closing")
+	walDir := flag.String("waldir", "wal", "directory of the protocol log used by presumed-abort and presumed-commit")
 	flag.Var(&followersArr, "follower", "follower address")
 	flag.Var(&whitelistArr, "whitelist", "allowed hosts")
 	flag.Parse()
@@ -76,9 +133,33 @@ func Get() *Config {
 		if !Includes(whitelistArr, "127.0.0.1") {
 			whitelistArr = append(whitelistArr, "127.0.0.1")
 		}
-		return &Config{*role, *nodeaddr, *coordinator,
-			followersArr, whitelistArr, *commitType,
-			*timeout, *hooks}
+		return &Config{
+			Role:             *role,
+			NodeAddr:         *nodeaddr,
+			Coordinator:      *coordinator,
+			Followers:        followersArr,
+			Whitelist:        whitelistArr,
+			CommitType:       *commitType,
+			Timeout:          *timeout,
+			ProposeTimeout:   *proposeTimeout,
+			PrecommitTimeout: *precommitTimeout,
+			CommitTimeout:    *commitTimeout,
+			Hooks:            *hooks,
+			SnapshotDir:      *snapshotDir,
+			SnapshotInterval: *snapshotInterval,
+			Restore:          *restore,
+			Backup:           *backup,
+			DedupSize:        *dedupSize,
+			BatchSize:        *batchSize,
+			BatchLinger:      *batchLinger,
+			MetricsAddr:      *metricsAddr,
+			HttpAddr:         *httpAddr,
+			WalDir:           *walDir,
+			LockPolicy:       *lockPolicy,
+			LockTimeout:      *lockTimeout,
+			Backend:          *backend,
+			ShutdownTimeout:  *shutdownTimeout,
+		}
 	}
 
 	//指定了配置文件
@@ -104,10 +185,34 @@ func Get() *Config {
 		svrConfig.Whitelist = append(svrConfig.Whitelist, "127.0.0.1")
 	}
 
-	return &Config{svrConfig.Role, svrConfig.NodeAddr,
-		svrConfig.Coordinator, svrConfig.Followers,
-		svrConfig.Whitelist, svrConfig.CommitType,
-		svrConfig.Timeout, svrConfig.Hooks}
+	if svrConfig.Timeout == 0 {
+		svrConfig.Timeout = *timeout
+	}
+	if svrConfig.SnapshotDir == "" {
+		svrConfig.SnapshotDir = *snapshotDir
+	}
+	if svrConfig.DedupSize == 0 {
+		svrConfig.DedupSize = *dedupSize
+	}
+	if svrConfig.BatchSize == 0 {
+		svrConfig.BatchSize = *batchSize
+	}
+	if svrConfig.WalDir == "" {
+		svrConfig.WalDir = *walDir
+	}
+	if svrConfig.LockPolicy == "" {
+		svrConfig.LockPolicy = *lockPolicy
+	}
+	if svrConfig.Backend == "" {
+		svrConfig.Backend = *backend
+	}
+	if svrConfig.ShutdownTimeout == 0 {
+		svrConfig.ShutdownTimeout = *shutdownTimeout
+	}
+	//恢复和备份只从命令行指定
+	svrConfig.Restore, svrConfig.Backup = *restore, *backup
+
+	return &svrConfig
 }
 
 func Includes(arr []string, value string) bool {
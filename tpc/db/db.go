@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
 
@@ -9,44 +10,166 @@ import (
 )
 
 type Database interface {
-	Put(key string, value []byte) error
+	//index是这次写入对应的提交高度
+	Put(index uint64, key string, value []byte) error
+	Delete(index uint64, key string) error
+	//key不存在或者已经删除时返回nil，值为空时返回长度为0的非nil切片
 	Get(key string) ([]byte, error)
+	//按key的字典序返回[start, end)区间内每个key的最新值，end为空表示不设上限
+	Scan(start, end string, limit int) ([]*KV, error)
+	//按提交高度的顺序返回[from, to)高度之间key在[start, end)区间内的所有写入记录，包括删除
+	Since(from, to uint64, start, end string) ([]*KV, error)
+	//返回index之前（不包含）每个key的最终值，用来生成快照
+	At(index uint64) ([]*KV, error)
+	//删除index之前已经被覆盖或者删除的记录，每个key只保留最新的一条
+	Compact(index uint64) error
+	//清空现有数据，换成快照中的数据
+	Restore(kvs []*KV) error
+	//下一个要提交的高度，没有数据时为0
+	Height() (uint64, error)
 	Close() error
 }
 
 func New(address, username, password string) (Database, error) {
-	db := getDBInstance(address, username, password)
-	return &DB{Instance: db}, nil
+	db := &DB{Instance: getDBInstance(address, username, password)}
+	if err := db.migrate(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+//key按字节比较：默认的collation不区分大小写，也不是按字节排序，范围扫描和分页会漏掉或者重复key，
+//已经存在的表把key列改成varbinary
+func (db *DB) migrate() error {
+	if err := db.Instance.AutoMigrate(&KV{}).Error; err != nil {
+		return err
+	}
+	var dataType string
+	row := db.Instance.Raw("SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() "+
+		"AND TABLE_NAME = ? AND COLUMN_NAME = 'key'", db.Instance.NewScope(&KV{}).TableName()).Row()
+	if err := row.Scan(&dataType); err != nil {
+		return err
+	}
+	if dataType == "varbinary" {
+		return nil
+	}
+	return db.Instance.Model(&KV{}).ModifyColumn("key", keyType).Error
 }
 
 type DB struct {
 	Instance *gorm.DB
 }
 
-func (db *DB) Put(key string, value []byte) error {
+func (db *DB) Put(index uint64, key string, value []byte) error {
+	if err := db.Instance.AutoMigrate(&KV{}).Error; err != nil {
+		return err
+	}
+	kv := &KV{
+		Key:         key,
+		Value:       string(value),
+		CommitIndex: index,
+	}
+	return db.Instance.Create(&kv).Error
+}
 
-	//应该是这样的写法
-	//if err := db.Instance.AutoMigrate(&KV{}).Error; err != nil {
-	//	return err
-	//}
-	//
-	//if err :=db.Instance.Create(&KV{}).Error; err != nil {
-	//	return err
-	//}
-	db.Instance.AutoMigrate(&KV{})
+//删除也是追加一行墓碑记录，这样Since能把删除回放给watcher
+func (db *DB) Delete(index uint64, key string) error {
+	if err := db.Instance.AutoMigrate(&KV{}).Error; err != nil {
+		return err
+	}
 	kv := &KV{
-		Model: gorm.Model{},
-		Key:   key,
-		Value: string(value),
+		Key:         key,
+		CommitIndex: index,
+		Tombstone:   true,
 	}
-	db.Instance.Create(&kv)
-	return nil
+	return db.Instance.Create(&kv).Error
 }
 
 func (db *DB) Get(key string) ([]byte, error) {
 	var kv KV
-	db.Instance.Where("key=", key).Last(&kv)
-	return []byte(kv.Value), nil
+	if err := db.Instance.Where("`key` = ?", key).Last(&kv).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+	if kv.ID == 0 || kv.Tombstone {
+		return nil, nil
+	}
+	return append([]byte{}, kv.Value...), nil
+}
+
+func (db *DB) Scan(start, end string, limit int) ([]*KV, error) {
+	var kvs []*KV
+	//Put是追加写，同一个key可能有多行，只取id最大的那一行
+	latest := db.Instance.Model(&KV{}).Select("MAX(id)").Group("`key`").SubQuery()
+	query := inRange(db.Instance.Where("id IN (?)", latest).Where("tombstone = ?", false), start, end)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Order("`key`").Find(&kvs).Error; err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+func (db *DB) Since(from, to uint64, start, end string) ([]*KV, error) {
+	var kvs []*KV
+	query := inRange(db.Instance.Where("commit_index >= ? AND commit_index < ?", from, to), start, end)
+	if err := query.Order("commit_index").Order("id").Find(&kvs).Error; err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+//inRange限定key在[start, end)之间。边界按十六进制传给UNHEX，prefixEnd加一之后的边界不一定是合法的UTF-8，
+//直接作为字符串传给MySQL会被拒绝
+func inRange(query *gorm.DB, start, end string) *gorm.DB {
+	query = query.Where("`key` >= UNHEX(?)", hex.EncodeToString([]byte(start)))
+	if end != "" {
+		query = query.Where("`key` < UNHEX(?)", hex.EncodeToString([]byte(end)))
+	}
+	return query
+}
+
+func (db *DB) At(index uint64) ([]*KV, error) {
+	var kvs []*KV
+	latest := db.Instance.Model(&KV{}).Select("MAX(id)").Where("commit_index < ?", index).Group("`key`").SubQuery()
+	if err := db.Instance.Where("id IN (?)", latest).Where("tombstone = ?", false).Order("`key`").Find(&kvs).Error; err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+func (db *DB) Compact(index uint64) error {
+	//mysql不允许在delete的子查询里直接引用同一张表，需要再包一层
+	return db.Instance.Exec("DELETE FROM kvs WHERE commit_index < ? AND (tombstone = ? OR id NOT IN "+
+		"(SELECT id FROM (SELECT MAX(id) AS id FROM kvs GROUP BY `key`) AS latest))", index, true).Error
+}
+
+func (db *DB) Restore(kvs []*KV) error {
+	db.Instance.AutoMigrate(&KV{})
+	tx := db.Instance.Begin()
+	if err := tx.Unscoped().Delete(&KV{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, kv := range kvs {
+		if err := tx.Create(&KV{Key: kv.Key, Value: kv.Value, CommitIndex: kv.CommitIndex}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (db *DB) Height() (uint64, error) {
+	var kv KV
+	err := db.Instance.Unscoped().Order("commit_index desc").First(&kv).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return kv.CommitIndex + 1, nil
 }
 
 func (db *DB) Close() error {
@@ -72,8 +195,12 @@ func getDBInstance(address, username, password string) *gorm.DB {
 	return db
 }
 
+const keyType = "varbinary(255)"
+
 type KV struct {
 	gorm.Model
-	Key   string
-	Value string
+	Key         string `gorm:"type:varbinary(255)"`
+	Value       string
+	CommitIndex uint64 `gorm:"index"`
+	Tombstone   bool
 }
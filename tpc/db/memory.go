@@ -0,0 +1,147 @@
+package db
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+//Memory是保存在内存里的Database，和DB一样追加写入，用来在进程内跑节点，比如检查线性一致性的集群
+type Memory struct {
+	kvs    []*KV
+	nextID uint
+	mu     sync.RWMutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Put(index uint64, key string, value []byte) error {
+	m.append(&KV{Key: key, Value: string(value), CommitIndex: index})
+	return nil
+}
+
+func (m *Memory) Delete(index uint64, key string) error {
+	m.append(&KV{Key: key, CommitIndex: index, Tombstone: true})
+	return nil
+}
+
+func (m *Memory) append(kv *KV) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	kv.ID = m.nextID
+	m.kvs = append(m.kvs, kv)
+}
+
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := len(m.kvs) - 1; i >= 0; i-- {
+		if kv := m.kvs[i]; kv.Key == key {
+			if kv.Tombstone {
+				return nil, nil
+			}
+			return append([]byte{}, kv.Value...), nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Memory) Scan(start, end string, limit int) ([]*KV, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	kvs := latest(m.kvs, func(kv *KV) bool { return kv.Key >= start && (end == "" || kv.Key < end) })
+	if limit > 0 && len(kvs) > limit {
+		kvs = kvs[:limit]
+	}
+	return kvs, nil
+}
+
+func (m *Memory) Since(from, to uint64, start, end string) ([]*KV, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var kvs []*KV
+	for _, kv := range m.kvs {
+		if kv.CommitIndex >= from && kv.CommitIndex < to && kv.Key >= start && (end == "" || kv.Key < end) {
+			kvs = append(kvs, copyKV(kv))
+		}
+	}
+	sort.SliceStable(kvs, func(i, j int) bool { return kvs[i].CommitIndex < kvs[j].CommitIndex })
+	return kvs, nil
+}
+
+func (m *Memory) At(index uint64) ([]*KV, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return latest(m.kvs, func(kv *KV) bool { return kv.CommitIndex < index }), nil
+}
+
+func (m *Memory) Compact(index uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last := map[string]uint{}
+	for _, kv := range m.kvs {
+		last[kv.Key] = kv.ID
+	}
+	kvs := m.kvs[:0]
+	for _, kv := range m.kvs {
+		if kv.CommitIndex >= index || (!kv.Tombstone && last[kv.Key] == kv.ID) {
+			kvs = append(kvs, kv)
+		}
+	}
+	m.kvs = kvs
+	return nil
+}
+
+func (m *Memory) Restore(kvs []*KV) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kvs = nil
+	for _, kv := range kvs {
+		m.nextID++
+		m.kvs = append(m.kvs, &KV{Model: gorm.Model{ID: m.nextID}, Key: kv.Key, Value: kv.Value, CommitIndex: kv.CommitIndex})
+	}
+	return nil
+}
+
+func (m *Memory) Height() (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var height uint64
+	for _, kv := range m.kvs {
+		if kv.CommitIndex+1 > height {
+			height = kv.CommitIndex + 1
+		}
+	}
+	return height, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+//latest返回满足match的记录里每个key的最新值，不包括已经删除的key，按key排序
+func latest(all []*KV, match func(kv *KV) bool) []*KV {
+	last := map[string]*KV{}
+	for _, kv := range all {
+		if match(kv) {
+			last[kv.Key] = kv
+		}
+	}
+	kvs := make([]*KV, 0, len(last))
+	for _, kv := range last {
+		if !kv.Tombstone {
+			kvs = append(kvs, copyKV(kv))
+		}
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+func copyKV(kv *KV) *KV {
+	c := *kv
+	return &c
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+//xid不存在，回滚一个已经结束的分支时会遇到
+const errXAUnknownXid = 1397
+
+//XA把参与者的写入放进数据库的XA事务里：投票之前XA PREPARE，数据已经持久化并且通过了约束检查，
+//之后的XA COMMIT不会再因为数据冲突失败。xid的gtrid是协议里的事务，bqual是节点，
+//多个节点共用一个MySQL时XA RECOVER只认自己的分支
+type XA struct {
+	db     *DB
+	branch string
+	table  string
+	//已经PREPARE还没有提交或回滚的分支
+	prepared map[string]bool
+	mu       sync.Mutex
+}
+
+//NewXA用XA RECOVER找回上次崩溃时已经PREPARE的分支
+func NewXA(database Database, branch string) (*XA, error) {
+	d, ok := database.(*DB)
+	if !ok {
+		return nil, errors.New("database does not support XA")
+	}
+	if err := d.Instance.AutoMigrate(&KV{}).Error; err != nil {
+		return nil, err
+	}
+	x := &XA{
+		db:       d,
+		branch:   branch,
+		table:    d.Instance.NewScope(&KV{}).TableName(),
+		prepared: map[string]bool{},
+	}
+	gtrids, err := x.Recover()
+	if err != nil {
+		return nil, err
+	}
+	for _, gtrid := range gtrids {
+		x.prepared[gtrid] = true
+	}
+	return x, nil
+}
+
+//XA语句不能用占位符，只能拼进sql，这里拒绝会破坏引号的字符
+func (x *XA) xid(gtrid string) (string, error) {
+	for _, part := range []string{gtrid, x.branch} {
+		if part == "" || len(part) > 64 || strings.ContainsAny(part, `'\`) {
+			return "", errors.Errorf("invalid xid part %q", part)
+		}
+	}
+	return fmt.Sprintf("'%s','%s'", gtrid, x.branch), nil
+}
+
+//Prepare在一个XA分支里写入kvs并PREPARE，失败时回滚这个分支
+func (x *XA) Prepare(gtrid string, kvs []*KV) error {
+	return x.write(gtrid, kvs, false)
+}
+
+//CommitOnePhase在一个XA分支里写入kvs并直接提交，只有一个参与者时使用
+func (x *XA) CommitOnePhase(gtrid string, kvs []*KV) error {
+	return x.write(gtrid, kvs, true)
+}
+
+func (x *XA) write(gtrid string, kvs []*KV, onePhase bool) error {
+	xid, err := x.xid(gtrid)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	//XA START到XA END必须在同一个连接上
+	conn, err := x.db.Instance.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "XA START "+xid); err != nil {
+		return errors.Wrap(err, "failed to start xa branch")
+	}
+	now := time.Now()
+	insert := fmt.Sprintf("INSERT INTO `%s` (`created_at`, `updated_at`, `key`, `value`, `commit_index`, `tombstone`) VALUES (?, ?, ?, ?, ?, ?)", x.table)
+	for _, kv := range kvs {
+		if _, err = conn.ExecContext(ctx, insert, now, now, kv.Key, kv.Value, kv.CommitIndex, kv.Tombstone); err != nil {
+			break
+		}
+	}
+	if _, e := conn.ExecContext(ctx, "XA END "+xid); err == nil {
+		err = e
+	}
+	if err == nil {
+		if onePhase {
+			_, err = conn.ExecContext(ctx, "XA COMMIT "+xid+" ONE PHASE")
+			return errors.Wrap(err, "failed to commit xa branch")
+		}
+		_, err = conn.ExecContext(ctx, "XA PREPARE "+xid)
+	}
+	if err != nil {
+		conn.ExecContext(ctx, "XA ROLLBACK "+xid)
+		return errors.Wrap(err, "failed to prepare xa branch")
+	}
+	x.mu.Lock()
+	x.prepared[gtrid] = true
+	x.mu.Unlock()
+	return nil
+}
+
+//Commit提交已经PREPARE的分支，PREPARE之后可以在任意连接上提交
+func (x *XA) Commit(gtrid string) error {
+	xid, err := x.xid(gtrid)
+	if err != nil {
+		return err
+	}
+	if err := x.db.Instance.Exec("XA COMMIT " + xid).Error; err != nil {
+		return errors.Wrap(err, "failed to commit xa branch")
+	}
+	x.forget(gtrid)
+	return nil
+}
+
+//Rollback回滚分支，分支已经不存在时不报错
+func (x *XA) Rollback(gtrid string) error {
+	xid, err := x.xid(gtrid)
+	if err != nil {
+		return err
+	}
+	err = x.db.Instance.Exec("XA ROLLBACK " + xid).Error
+	if e, ok := errors.Cause(err).(*mysql.MySQLError); ok && e.Number == errXAUnknownXid {
+		err = nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to rollback xa branch")
+	}
+	x.forget(gtrid)
+	return nil
+}
+
+func (x *XA) Prepared(gtrid string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.prepared[gtrid]
+}
+
+func (x *XA) forget(gtrid string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.prepared, gtrid)
+}
+
+//Recover返回这个节点上所有已经PREPARE但还没有结束的分支的gtrid
+func (x *XA) Recover() ([]string, error) {
+	rows, err := x.db.Instance.DB().Query("XA RECOVER")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to recover xa branches")
+	}
+	defer rows.Close()
+	var gtrids []string
+	for rows.Next() {
+		var (
+			formatID, gtridLen, bqualLen int
+			data                         string
+		)
+		if err := rows.Scan(&formatID, &gtridLen, &bqualLen, &data); err != nil {
+			return nil, err
+		}
+		if gtridLen+bqualLen > len(data) || data[gtridLen:gtridLen+bqualLen] != x.branch {
+			continue
+		}
+		gtrids = append(gtrids, data[:gtridLen])
+	}
+	return gtrids, rows.Err()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sysphusking/dsts/2pc/client"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//对集群施加可配置的读写压力，报告吞吐、每种操作的延迟分位数和abort率，
+//在同样的机器上分别用不同的committype启动集群跑一遍就可以对比
+func main() {
+	addrs := flag.String("addrs", "localhost:3000", "comma separated node addresses")
+	clients := flag.Int("clients", 16, "number of concurrent workers")
+	conns := flag.Int("conns", 4, "number of client connections shared by the workers")
+	duration := flag.Duration("duration", 30*time.Second, "how long to run, after warmup")
+	warmup := flag.Duration("warmup", 0, "run this long before measuring")
+	requests := flag.Int64("requests", 0, "stop after this many operations (0 runs for duration)")
+	keys := flag.Int("keys", 10000, "number of distinct keys")
+	dist := flag.String("dist", "uniform", "key distribution: uniform, zipf or sequential")
+	zipfS := flag.Float64("zipfs", 1.1, "zipf skew, must be > 1")
+	prefix := flag.String("prefix", "bench-", "key prefix")
+	valueSize := flag.Int("valuesize", 128, "value size in bytes")
+	reads := flag.Float64("reads", 0.5, "ratio of gets, the rest are puts")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout of each operation")
+	attempts := flag.Int("attempts", 1, "client attempts per operation; more than 1 retries aborts and hides them from the abort rate")
+	label := flag.String("label", "", "label of this run in the report, e.g. the commit type")
+	format := flag.String("format", "text", "report format: text or json")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed")
+	flag.Parse()
+
+	if *clients <= 0 || *conns <= 0 || *keys <= 0 {
+		fmt.Fprintln(os.Stderr, "clients, conns and keys must be positive")
+		os.Exit(2)
+	}
+	if *dist != "uniform" && *dist != "zipf" && *dist != "sequential" {
+		fmt.Fprintf(os.Stderr, "unknown key distribution %q\n", *dist)
+		os.Exit(2)
+	}
+	//rand.NewZipf在s<=1或者只有一个key时返回nil
+	if *dist == "zipf" && (*zipfS <= 1 || *keys < 2) {
+		fmt.Fprintln(os.Stderr, "zipf needs -zipfs > 1 and -keys >= 2")
+		os.Exit(2)
+	}
+	if *attempts <= 0 {
+		fmt.Fprintln(os.Stderr, "attempts must be positive")
+		os.Exit(2)
+	}
+
+	pool := make([]*client.CommitClient, *conns)
+	for i := range pool {
+		//默认不重试，每次abort都算进abort率
+		cli, err := client.NewCluster(strings.Split(*addrs, ","), client.WithRetry(*attempts, 50*time.Millisecond, time.Second))
+		if err != nil {
+			panic(err)
+		}
+		defer cli.Close()
+		pool[i] = cli
+	}
+
+	var (
+		stats    = map[string]*opStats{"put": newOpStats(), "get": newOpStats()}
+		measure  int32
+		issued   int64
+		sequence uint64
+		wg       sync.WaitGroup
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < *clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cli := pool[i%len(pool)]
+			rnd := rand.New(rand.NewSource(*seed + int64(i)))
+			var zipf *rand.Zipf
+			if *dist == "zipf" {
+				zipf = rand.NewZipf(rnd, *zipfS, 1, uint64(*keys-1))
+			}
+			value := make([]byte, *valueSize)
+			for ctx.Err() == nil {
+				if *requests > 0 && atomic.AddInt64(&issued, 1) > *requests {
+					return
+				}
+				var k uint64
+				switch *dist {
+				case "zipf":
+					k = zipf.Uint64()
+				case "sequential":
+					k = atomic.AddUint64(&sequence, 1) % uint64(*keys)
+				default:
+					k = uint64(rnd.Intn(*keys))
+				}
+				key := fmt.Sprintf("%s%d", *prefix, k)
+
+				opCtx, opCancel := context.WithTimeout(ctx, *timeout)
+				op, start := "put", time.Now()
+				var err error
+				if rnd.Float64() < *reads {
+					op = "get"
+					_, err = cli.Get(opCtx, key)
+				} else {
+					rnd.Read(value)
+					var resp *pb.Response
+					if resp, err = cli.Put(opCtx, key, value); err == nil && resp.Type != pb.Type_ACK {
+						err = fmt.Errorf("put not acknowledged: %s", resp.Type)
+					}
+				}
+				opCancel()
+				//停止时被取消的操作不计入
+				if atomic.LoadInt32(&measure) == 1 && ctx.Err() == nil {
+					stats[op].record(time.Since(start), err)
+				}
+			}
+		}(i)
+	}
+
+	time.Sleep(*warmup)
+	atomic.StoreInt32(&measure, 1)
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-time.After(*duration):
+	case <-done:
+	}
+	elapsed := time.Since(start)
+	cancel()
+	wg.Wait()
+
+	report := &Report{
+		Label:      *label,
+		Clients:    *clients,
+		Keys:       *keys,
+		Dist:       *dist,
+		ValueSize:  *valueSize,
+		ReadRatio:  *reads,
+		Duration:   elapsed,
+		Operations: map[string]*OpReport{},
+	}
+	for name, s := range stats {
+		op := s.report(elapsed)
+		report.Operations[name] = op
+		report.Throughput += op.Throughput
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			panic(err)
+		}
+		return
+	}
+	report.writeText(os.Stdout)
+}
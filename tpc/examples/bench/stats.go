@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/client"
+)
+
+//opStats是一种操作的统计，latencies只记录成功的操作
+type opStats struct {
+	latencies []time.Duration
+	errors    int
+	aborts    int
+	reasons   map[string]int
+	mu        sync.Mutex
+}
+
+func newOpStats() *opStats {
+	return &opStats{reasons: map[string]int{}}
+}
+
+func (s *opStats) record(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.latencies = append(s.latencies, d)
+		return
+	}
+	s.errors++
+	//follower拒绝或者这一轮被回滚都算abort，按拒绝的原因分类，其余的（超时、连接断开）只算错误
+	if reason := client.Reason(err); len(client.Votes(err)) > 0 || status.Code(err) == codes.Aborted {
+		s.aborts++
+		s.reasons[strings.ToLower(reason.String())]++
+	}
+}
+
+type Latency struct {
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	P999 time.Duration `json:"p999"`
+	Max  time.Duration `json:"max"`
+}
+
+type OpReport struct {
+	Ops        int     `json:"ops"`
+	Errors     int     `json:"errors"`
+	Aborts     int     `json:"aborts"`
+	AbortRate  float64 `json:"abort_rate"`
+	Throughput float64 `json:"ops_per_sec"`
+	//延迟单位是ns
+	Latency      Latency        `json:"latency_ns"`
+	AbortReasons map[string]int `json:"abort_reasons,omitempty"`
+}
+
+type Report struct {
+	Label      string               `json:"label,omitempty"`
+	Clients    int                  `json:"clients"`
+	Keys       int                  `json:"keys"`
+	Dist       string               `json:"dist"`
+	ValueSize  int                  `json:"value_size"`
+	ReadRatio  float64              `json:"read_ratio"`
+	Duration   time.Duration        `json:"duration_ns"`
+	Throughput float64              `json:"ops_per_sec"`
+	Operations map[string]*OpReport `json:"operations"`
+}
+
+func (s *opStats) report(elapsed time.Duration) *OpReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &OpReport{Ops: len(s.latencies), Errors: s.errors, Aborts: s.aborts}
+	if total := len(s.latencies) + s.errors; total > 0 {
+		r.AbortRate = float64(s.aborts) / float64(total)
+	}
+	if len(s.reasons) > 0 {
+		r.AbortReasons = s.reasons
+	}
+	r.Throughput = float64(len(s.latencies)) / elapsed.Seconds()
+	if len(s.latencies) == 0 {
+		return r
+	}
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	var sum time.Duration
+	for _, d := range s.latencies {
+		sum += d
+	}
+	r.Latency = Latency{
+		Mean: sum / time.Duration(len(s.latencies)),
+		P50:  percentile(s.latencies, 0.5),
+		P90:  percentile(s.latencies, 0.9),
+		P99:  percentile(s.latencies, 0.99),
+		P999: percentile(s.latencies, 0.999),
+		Max:  s.latencies[len(s.latencies)-1],
+	}
+	return r
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (r *Report) writeText(w io.Writer) {
+	if r.Label != "" {
+		fmt.Fprintf(w, "%s\n", r.Label)
+	}
+	fmt.Fprintf(w, "%d clients, %d keys (%s), %d byte values, %.0f%% reads, %s\n",
+		r.Clients, r.Keys, r.Dist, r.ValueSize, r.ReadRatio*100, r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "throughput: %.1f ops/s\n\n", r.Throughput)
+	fmt.Fprintf(w, "%-7s %9s %10s %8s %8s %10s %10s %10s %10s %10s %10s\n",
+		"op", "ops", "ops/s", "errors", "abort%", "mean", "p50", "p90", "p99", "p99.9", "max")
+	names := make([]string, 0, len(r.Operations))
+	for name := range r.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		op := r.Operations[name]
+		l := op.Latency
+		fmt.Fprintf(w, "%-7s %9d %10.1f %8d %7.2f%% %10s %10s %10s %10s %10s %10s\n",
+			name, op.Ops, op.Throughput, op.Errors, op.AbortRate*100,
+			round(l.Mean), round(l.P50), round(l.P90), round(l.P99), round(l.P999), round(l.Max))
+		for reason, n := range op.AbortReasons {
+			fmt.Fprintf(w, "        aborted %d times: %s\n", n, reason)
+		}
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
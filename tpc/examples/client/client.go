@@ -17,8 +17,13 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	defer cli.Close()
 	resp, err := cli.Put(context.Background(), "1", []byte("2"))
 	if err != nil {
+		//follower拒绝时可以看到是哪个节点因为什么拒绝的
+		for _, vote := range client.Votes(err) {
+			fmt.Printf("%s: %s %s\n", vote.Node, vote.Reason, vote.Message)
+		}
 		panic(err)
 	}
 	fmt.Println(resp)
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sysphusking/dsts/2pc/client"
+	"github.com/sysphusking/dsts/2pc/lincheck"
+)
+
+//对运行中的集群并发读写一段时间，检查历史是否满足线性一致性，期间可以手动杀掉或重启节点
+func main() {
+	addrs := flag.String("addrs", "localhost:3000", "comma separated node addresses")
+	clients := flag.Int("clients", 4, "number of concurrent clients")
+	keys := flag.Int("keys", 3, "number of keys")
+	prefix := flag.String("prefix", "lincheck-", "key prefix")
+	duration := flag.Duration("duration", 10*time.Second, "how long to run the workload")
+	reads := flag.Float64("reads", 0.5, "ratio of gets")
+	deletes := flag.Float64("deletes", 0.1, "ratio of deletes")
+	opTimeout := flag.Duration("optimeout", 2*time.Second, "timeout of each operation")
+	checkTimeout := flag.Duration("checktimeout", time.Minute, "give up checking after this long (0 never gives up)")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed of the workload")
+	out := flag.String("out", "lincheck.html", "where to write the visualization of violations")
+	flag.Parse()
+
+	w := lincheck.Workload{
+		Keys:        *keys,
+		Prefix:      *prefix,
+		ReadRatio:   *reads,
+		DeleteRatio: *deletes,
+		OpTimeout:   *opTimeout,
+		Seed:        *seed,
+	}
+	for i := 0; i < *clients; i++ {
+		cli, err := client.NewCluster(strings.Split(*addrs, ","))
+		if err != nil {
+			panic(err)
+		}
+		defer cli.Close()
+		w.Clients = append(w.Clients, lincheck.FromClient(cli))
+	}
+
+	fmt.Printf("running %d clients on %d keys for %s, seed %d\n", *clients, *keys, *duration, *seed)
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	history := w.Run(ctx)
+	cancel()
+
+	report := lincheck.Check(history, *checkTimeout)
+	fmt.Print(report)
+	if report.Ok {
+		return
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		panic(err)
+	}
+	if err := lincheck.Visualize(f, report); err != nil {
+		panic(err)
+	}
+	f.Close()
+	fmt.Printf("visualization written to %s\n", *out)
+	os.Exit(1)
+}
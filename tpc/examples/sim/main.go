@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sysphusking/dsts/2pc/protocol"
+	"github.com/sysphusking/dsts/2pc/sim"
+)
+
+//用不同的seed跑很多次模拟的调度，发现违反原子性的调度时打印它的seed和最后的事件，
+//用-seed <seed> -runs 1可以重放同一个调度
+func main() {
+	cfg := sim.DefaultConfig()
+	mode := flag.String("committype", cfg.Mode.String(), "two-phase, three-phase, presumed-abort or presumed-commit")
+	flag.IntVar(&cfg.Participants, "participants", cfg.Participants, "number of participants")
+	flag.IntVar(&cfg.Rounds, "rounds", cfg.Rounds, "rounds per schedule")
+	flag.Float64Var(&cfg.Loss, "loss", cfg.Loss, "probability of losing a message")
+	flag.Float64Var(&cfg.Duplicate, "duplicate", cfg.Duplicate, "probability of duplicating a message")
+	flag.Float64Var(&cfg.CrashRate, "crash", cfg.CrashRate, "probability of crashing a node after each event")
+	flag.Float64Var(&cfg.NoVote, "novote", cfg.NoVote, "probability of a participant voting no")
+	flag.Float64Var(&cfg.ReadOnly, "readonly", cfg.ReadOnly, "probability of a yes vote being read-only")
+	flag.DurationVar(&cfg.MaxDelay, "maxdelay", cfg.MaxDelay, "max message delay")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the first schedule")
+	runs := flag.Int("runs", 1000, "number of schedules to explore")
+	verbose := flag.Bool("v", false, "print the trace of the failing schedule")
+	flag.Parse()
+
+	var err error
+	if cfg.Mode, err = protocol.ParseMode(*mode); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("exploring %d schedules of %s from seed %d\n", *runs, cfg.Mode, *seed)
+	failed := sim.Explore(cfg, *seed, *runs)
+	if failed == nil {
+		fmt.Println("no atomicity violation found")
+		return
+	}
+	if *verbose {
+		fmt.Println(strings.Join(failed.Trace, "\n"))
+	}
+	fmt.Printf("seed %d violates atomicity after %d steps (%s):\n", failed.Seed, failed.Steps, failed.Time)
+	for _, v := range failed.Violations {
+		fmt.Println("  " + v)
+	}
+	fmt.Printf("replay with -committype %s -participants %d -seed %d -runs 1 -v\n", cfg.Mode, cfg.Participants, failed.Seed)
+	os.Exit(1)
+}
@@ -0,0 +1,233 @@
+package lincheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//KeyResult是一个key上的检查结果
+type KeyResult struct {
+	Key string
+	Ops []Operation
+	Ok  bool
+	//检查超时，结果不确定
+	TimedOut bool
+	//找到的最长的可以线性化的一组操作，元素是Ops的下标，按生效的顺序排列，
+	//不满足线性一致性时，剩下的操作里至少有一个无论放在哪里都不对
+	Longest []int
+}
+
+type Report struct {
+	Ok       bool
+	TimedOut bool
+	//按key排序
+	Keys []KeyResult
+}
+
+//Violations返回不满足线性一致性的key
+func (r Report) Violations() []KeyResult {
+	var bad []KeyResult
+	for _, k := range r.Keys {
+		if !k.Ok && !k.TimedOut {
+			bad = append(bad, k)
+		}
+	}
+	return bad
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	ops := 0
+	for _, k := range r.Keys {
+		ops += len(k.Ops)
+	}
+	switch {
+	case r.Ok:
+		fmt.Fprintf(&b, "linearizable: %d operations on %d keys\n", ops, len(r.Keys))
+	case r.TimedOut && len(r.Violations()) == 0:
+		fmt.Fprintf(&b, "unknown: timed out checking %d operations on %d keys\n", ops, len(r.Keys))
+	default:
+		fmt.Fprintf(&b, "NOT linearizable: %d operations on %d keys\n", ops, len(r.Keys))
+	}
+	for _, k := range r.Keys {
+		if !k.Ok {
+			writeKey(&b, k)
+		}
+	}
+	return b.String()
+}
+
+func writeKey(b *strings.Builder, k KeyResult) {
+	if k.TimedOut {
+		fmt.Fprintf(b, "  key %q: timed out\n", k.Key)
+		return
+	}
+	fmt.Fprintf(b, "  key %q: longest linearizable prefix has %d of %d operations\n", k.Key, len(k.Longest), len(k.Ops))
+	in := map[int]bool{}
+	for i, id := range k.Longest {
+		in[id] = true
+		fmt.Fprintf(b, "    %3d. %s\n", i+1, describe(k.Ops[id]))
+	}
+	for id, op := range k.Ops {
+		if !in[id] {
+			fmt.Fprintf(b, "      x  %s\n", describe(op))
+		}
+	}
+}
+
+func describe(op Operation) string {
+	ret := "pending"
+	if op.Return != Pending {
+		ret = time.Duration(op.Return).String()
+	}
+	value := fmt.Sprintf("%q", op.Value)
+	if op.Kind == Delete {
+		value = ""
+	}
+	return fmt.Sprintf("client %d %s %s [%s, %s]", op.Client, op.Kind, value, time.Duration(op.Call), ret)
+}
+
+//Check检查历史是否满足线性一致性，timeout为0时不限制时间，超时的key结果不确定
+func Check(history []Operation, timeout time.Duration) Report {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	report := Report{Ok: true}
+	for key, ops := range partition(history) {
+		sort.SliceStable(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+		k := KeyResult{Key: key, Ops: ops}
+		k.Ok, k.TimedOut, k.Longest = checkKey(ops, deadline)
+		report.Ok = report.Ok && k.Ok
+		report.TimedOut = report.TimedOut || k.TimedOut
+		report.Keys = append(report.Keys, k)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool { return report.Keys[i].Key < report.Keys[j].Key })
+	return report
+}
+
+//entry是历史里的一个调用或者返回事件，按时间串成双向链表
+type entry struct {
+	id   int
+	call bool
+	time int64
+	//调用事件对应的返回事件
+	match      *entry
+	prev, next *entry
+}
+
+func makeEntries(ops []Operation) *entry {
+	events := make([]*entry, 0, 2*len(ops))
+	for i, op := range ops {
+		ret := &entry{id: i, time: op.Return}
+		events = append(events, &entry{id: i, call: true, time: op.Call, match: ret}, ret)
+	}
+	//同一时刻的调用排在返回前面，看作相互重叠
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].time != events[j].time {
+			return events[i].time < events[j].time
+		}
+		return events[i].call && !events[j].call
+	})
+	head := &entry{id: -1}
+	prev := head
+	for _, e := range events {
+		prev.next, e.prev = e, prev
+		prev = e
+	}
+	return head
+}
+
+//lift把一个已经线性化的操作的调用和返回从链表里摘掉
+func lift(e *entry) {
+	e.prev.next, e.next.prev = e.next, e.prev
+	m := e.match
+	m.prev.next = m.next
+	if m.next != nil {
+		m.next.prev = m.prev
+	}
+}
+
+func unlift(e *entry) {
+	m := e.match
+	m.prev.next = m
+	if m.next != nil {
+		m.next.prev = m
+	}
+	e.prev.next, e.next.prev = e, e
+}
+
+type bitset []uint64
+
+func (b bitset) set(i int)   { b[i/64] |= 1 << uint(i%64) }
+func (b bitset) clear(i int) { b[i/64] &^= 1 << uint(i%64) }
+
+func (b bitset) key() string {
+	buf := make([]byte, 0, 8*len(b))
+	for _, w := range b {
+		for i := 0; i < 8; i++ {
+			buf = append(buf, byte(w>>(8*uint(i))))
+		}
+	}
+	return string(buf)
+}
+
+//checkKey用Wing & Gong的回溯搜索，加上Lowe对（已线性化的操作，状态）的记忆化：
+//按时间顺序尝试把还没有返回的操作依次线性化，遇到一个返回事件说明它的操作没法放进去，回退上一个选择
+func checkKey(ops []Operation, deadline time.Time) (bool, bool, []int) {
+	type frame struct {
+		e     *entry
+		state string
+	}
+	var (
+		head       = makeEntries(ops)
+		linearized = make(bitset, (len(ops)+63)/64)
+		seen       = map[string]bool{}
+		calls      []frame
+		longest    []int
+		state      string
+		steps      int
+	)
+	e := head.next
+	for head.next != nil {
+		if steps++; steps%1024 == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			return false, true, longest
+		}
+		if e.call {
+			if ok, next := step(state, ops[e.id]); ok {
+				linearized.set(e.id)
+				key := linearized.key() + "\x00" + next
+				if !seen[key] {
+					seen[key] = true
+					calls = append(calls, frame{e: e, state: state})
+					state = next
+					lift(e)
+					if len(calls) > len(longest) {
+						longest = longest[:0]
+						for _, f := range calls {
+							longest = append(longest, f.e.id)
+						}
+					}
+					e = head.next
+					continue
+				}
+				linearized.clear(e.id)
+			}
+			e = e.next
+			continue
+		}
+		//这个返回事件之前没有能放进去的操作，回退
+		if len(calls) == 0 {
+			return false, false, longest
+		}
+		top := calls[len(calls)-1]
+		calls = calls[:len(calls)-1]
+		state = top.state
+		linearized.clear(top.e.id)
+		unlift(top.e)
+		e = top.e.next
+	}
+	return true, false, longest
+}
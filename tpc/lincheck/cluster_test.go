@@ -0,0 +1,147 @@
+package lincheck_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/2pc/client"
+	"github.com/sysphusking/dsts/2pc/config"
+	"github.com/sysphusking/dsts/2pc/db"
+	"github.com/sysphusking/dsts/2pc/lincheck"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/server"
+)
+
+//follower拒绝写这个key的提案，写它的操作都以回滚结束
+const rejectedKey = "lincheck-2"
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func hooks(propose func(req *pb.ProposeRequest) bool) server.Option {
+	return func(s *server.Server) error {
+		s.ProposeHook = propose
+		s.CommitHook = func(req *pb.CommitRequest) bool { return true }
+		return nil
+	}
+}
+
+//startCluster在进程内启动一个coordinator和两个follower，数据保存在内存里，返回coordinator的地址和停止集群的函数
+func startCluster(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "lincheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var servers []*server.Server
+	stop := func() {
+		for _, s := range servers {
+			s.Stop()
+		}
+		os.RemoveAll(dir)
+	}
+
+	coordinator := freeAddr(t)
+	followers := []string{freeAddr(t), freeAddr(t)}
+	confs := []*config.Config{{Role: "coordinator", NodeAddr: coordinator, Followers: followers}}
+	for _, addr := range followers {
+		confs = append(confs, &config.Config{Role: "follower", NodeAddr: addr, Coordinator: coordinator})
+	}
+	reject := func(req *pb.ProposeRequest) bool {
+		if req.Key == rejectedKey {
+			return false
+		}
+		for _, e := range req.Entries {
+			if e.Key == rejectedKey {
+				return false
+			}
+		}
+		return true
+	}
+	for i, conf := range confs {
+		conf.CommitType = server.TWO_PHASE
+		conf.Timeout = 1000
+		conf.DedupSize = 1000
+		conf.ShutdownTimeout = 1000
+		conf.WalDir = filepath.Join(dir, conf.NodeAddr, "wal")
+		conf.SnapshotDir = filepath.Join(dir, conf.NodeAddr, "snapshots")
+		propose := func(req *pb.ProposeRequest) bool { return true }
+		if i > 0 {
+			propose = reject
+		}
+		s, err := server.NewCommitServer(conf, server.WithDB(db.NewMemory()), hooks(propose))
+		if err != nil {
+			stop()
+			t.Fatal(err)
+		}
+		s.Run()
+		servers = append(servers, s)
+	}
+	return coordinator, stop
+}
+
+//对进程内的集群并发读写，记录下来的历史要满足线性一致性
+func TestClusterHistory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a cluster for a few seconds")
+	}
+	log.SetLevel(log.WarnLevel)
+	addr, stop := startCluster(t)
+	defer stop()
+
+	w := lincheck.Workload{
+		Keys:        3,
+		Prefix:      "lincheck-",
+		ReadRatio:   0.5,
+		DeleteRatio: 0.1,
+		OpTimeout:   2 * time.Second,
+		Seed:        1,
+	}
+	for i := 0; i < 4; i++ {
+		cli, err := client.New(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cli.Close()
+		w.Clients = append(w.Clients, lincheck.FromClient(cli))
+	}
+
+	//被follower拒绝的写入一定没有生效
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := w.Clients[0].Put(ctx, rejectedKey, []byte("rejected"))
+	cancel()
+	if err == nil || lincheck.Applied(err) {
+		t.Fatalf("put rejected by a follower: got %v, want an error that was rolled back", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	history := w.Run(ctx)
+	cancel()
+	writes := 0
+	for _, op := range history {
+		if op.Kind != lincheck.Get {
+			writes++
+		}
+	}
+	if writes == 0 {
+		t.Fatal("no write completed")
+	}
+
+	report := lincheck.Check(history, time.Minute)
+	t.Logf("%d operations, %d writes", len(history), writes)
+	if !report.Ok {
+		t.Fatalf("history of %d operations is not linearizable:\n%s", len(history), report)
+	}
+}
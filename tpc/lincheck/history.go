@@ -0,0 +1,117 @@
+package lincheck
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/client"
+)
+
+type Kind int
+
+const (
+	Put Kind = iota
+	Get
+	Delete
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Put:
+		return "put"
+	case Get:
+		return "get"
+	default:
+		return "delete"
+	}
+}
+
+//Pending表示还没有返回的操作，不知道有没有生效的写入的Return也是它
+const Pending = math.MaxInt64
+
+//Operation是一次读写从发起到返回的记录，Call和Return是相对于记录开始的时间(ns)
+type Operation struct {
+	Client int
+	Kind   Kind
+	Key    string
+	//Put写入的值，或者Get读到的值，key不存在时为空
+	Value  string
+	Call   int64
+	Return int64
+}
+
+//Recorder记录并发读写的历史，可以在多个goroutine里同时使用
+type Recorder struct {
+	start time.Time
+	ops   []Operation
+	mu    sync.Mutex
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{start: time.Now()}
+}
+
+//Call是已经发起、还没有返回的一次操作
+type Call struct {
+	r  *Recorder
+	op Operation
+}
+
+func (r *Recorder) Invoke(client int, kind Kind, key string, value []byte) *Call {
+	return &Call{r: r, op: Operation{Client: client, Kind: kind, Key: key, Value: string(value), Call: r.now()}}
+}
+
+//Complete记录操作的结果：失败的读和确定没有生效的写会被丢掉，结果未知的写当作一直没有返回，
+//检查时它可以在发起之后的任意时刻生效，也可以不生效
+func (c *Call) Complete(value []byte, err error) {
+	op := c.op
+	switch {
+	case err == nil:
+		op.Return = c.r.now()
+		if op.Kind == Get {
+			op.Value = string(value)
+		}
+	case op.Kind == Get || !Applied(err):
+		return
+	default:
+		op.Return = Pending
+	}
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.ops = append(c.r.ops, op)
+}
+
+func (r *Recorder) now() int64 {
+	return int64(time.Since(r.start))
+}
+
+//History返回到目前为止记录的操作
+func (r *Recorder) History() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.ops...)
+}
+
+//Applied判断一次失败的写入是否可能已经生效：请求本身不合法，或者coordinator在做出提交决定之前回滚了
+//（错误的details里带着ABORTED）时一定没有写入，其余的错误（超时、连接断开、做出决定之后的失败）都可能已经写入
+func Applied(err error) bool {
+	if err == nil {
+		return true
+	}
+	if status.Code(err) == codes.InvalidArgument {
+		return false
+	}
+	return !client.RolledBack(err)
+}
+
+//KV是被检查的读写接口，真实集群用FromClient包装client.CommitClient，进程内的实现也可以直接接入
+type KV interface {
+	Put(ctx context.Context, key string, value []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
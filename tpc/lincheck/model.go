@@ -0,0 +1,22 @@
+package lincheck
+
+//register是每个key上的寄存器模型：Put和Delete覆盖当前值，Get必须读到当前值，不存在的key读到空值
+func step(state string, op Operation) (bool, string) {
+	switch op.Kind {
+	case Put:
+		return true, op.Value
+	case Delete:
+		return true, ""
+	default:
+		return op.Value == state, state
+	}
+}
+
+//partition按key拆分历史，每个key上的寄存器相互独立，可以分别检查
+func partition(history []Operation) map[string][]Operation {
+	keys := map[string][]Operation{}
+	for _, op := range history {
+		keys[op.Key] = append(keys[op.Key], op)
+	}
+	return keys
+}
@@ -0,0 +1,157 @@
+package lincheck
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+const (
+	chartWidth = 1000
+	rowHeight  = 28
+)
+
+type bar struct {
+	X, Y, Width  int
+	Label, Title string
+	//open表示结果未知、没有返回的写入
+	Open bool
+	//linearized表示在最长可线性化前缀里，Order是它生效的顺序
+	Linearized bool
+	Order      int
+}
+
+type keyView struct {
+	Key     string
+	Ok      bool
+	Height  int
+	Bars    []bar
+	Clients []clientRow
+	Text    string
+}
+
+type clientRow struct {
+	Y      int
+	Client int
+}
+
+var page = template.Must(template.New("lincheck").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>lincheck</title>
+<style>
+body { font-family: monospace; margin: 20px; }
+rect.linearized { fill: #b7e1b0; stroke: #3c8d2f; }
+rect.failed { fill: #f4b8b8; stroke: #c0392b; stroke-width: 2; }
+rect.open { stroke-dasharray: 4 3; }
+text { font-size: 11px; }
+pre { background: #f6f6f6; padding: 8px; }
+</style></head><body>
+<h2>{{.Summary}}</h2>
+<p>Green operations are the longest prefix the checker could linearize, numbered in the order they take effect.
+Red operations could not be placed after it. Dashed operations never returned, their outcome is unknown.</p>
+{{range .Keys}}
+<h3>key {{printf "%q" .Key}}{{if not .Ok}} — violation{{end}}</h3>
+<svg width="{{$.Width}}" height="{{.Height}}">
+{{range .Clients}}<text x="0" y="{{.Y}}">client {{.Client}}</text>
+{{end}}{{range .Bars}}<g><title>{{.Title}}</title>
+<rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="20" class="{{if .Linearized}}linearized{{else}}failed{{end}}{{if .Open}} open{{end}}"></rect>
+<text x="{{.X}}" y="{{.Y}}" dx="3" dy="14">{{if .Linearized}}#{{.Order}} {{end}}{{.Label}}</text></g>
+{{end}}</svg>
+<pre>{{.Text}}</pre>
+{{end}}
+</body></html>
+`))
+
+//Visualize把不满足线性一致性的key画成时间线写到w里，每个客户端一行，
+//report没有问题时只写出汇总
+func Visualize(w io.Writer, report Report) error {
+	keys := make([]keyView, 0)
+	for _, k := range report.Violations() {
+		keys = append(keys, view(k))
+	}
+	return page.Execute(w, struct {
+		Summary string
+		Width   int
+		Keys    []keyView
+	}{
+		Summary: firstLine(report.String()),
+		Width:   chartWidth + 100,
+		Keys:    keys,
+	})
+}
+
+func view(k KeyResult) keyView {
+	v := keyView{Key: k.Key, Ok: k.Ok}
+	order := map[int]int{}
+	for i, id := range k.Longest {
+		order[id] = i + 1
+	}
+
+	//时间轴覆盖所有已知的调用和返回，没有返回的操作画到最右边
+	var start, end int64 = -1, 0
+	rows := map[int]int{}
+	for _, op := range k.Ops {
+		if start < 0 || op.Call < start {
+			start = op.Call
+		}
+		if op.Call > end {
+			end = op.Call
+		}
+		if op.Return != Pending && op.Return > end {
+			end = op.Return
+		}
+		if _, ok := rows[op.Client]; !ok {
+			rows[op.Client] = len(rows)
+			v.Clients = append(v.Clients, clientRow{Y: len(v.Clients)*rowHeight + 14, Client: op.Client})
+		}
+	}
+	if end <= start {
+		end = start + 1
+	}
+	x := func(t int64) int {
+		return 100 + int(float64(t-start)/float64(end-start)*chartWidth)
+	}
+
+	for id, op := range k.Ops {
+		ret := op.Return
+		if ret == Pending {
+			ret = end
+		}
+		b := bar{
+			X:          x(op.Call),
+			Y:          rows[op.Client] * rowHeight,
+			Label:      label(op),
+			Title:      describe(op),
+			Open:       op.Return == Pending,
+			Linearized: order[id] > 0,
+			Order:      order[id],
+		}
+		if b.Width = x(ret) - b.X; b.Width < 4 {
+			b.Width = 4
+		}
+		v.Bars = append(v.Bars, b)
+	}
+	v.Height = len(v.Clients)*rowHeight + 8
+	var text strings.Builder
+	writeKey(&text, k)
+	v.Text = text.String()
+	return v
+}
+
+func label(op Operation) string {
+	switch op.Kind {
+	case Put:
+		return fmt.Sprintf("put %q", op.Value)
+	case Get:
+		return fmt.Sprintf("get %q", op.Value)
+	default:
+		return "delete"
+	}
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package lincheck
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/client"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//Workload让每个客户端在少数几个key上随机并发读写，写入的值各不相同，方便检查读到的是哪一次写入。
+//故障（杀掉节点、断网）由调用方在运行期间注入
+type Workload struct {
+	Clients []KV
+	//key的个数，越少冲突越多
+	Keys   int
+	Prefix string
+	//读和删除所占的比例，剩下的是Put
+	ReadRatio   float64
+	DeleteRatio float64
+	//每个操作的超时时间，为0时不设置
+	OpTimeout time.Duration
+	Seed      int64
+}
+
+//Run一直运行到ctx结束，返回记录下来的历史
+func (w Workload) Run(ctx context.Context) []Operation {
+	rec := NewRecorder()
+	keys := w.Keys
+	if keys <= 0 {
+		keys = 1
+	}
+	var wg sync.WaitGroup
+	for i, kv := range w.Clients {
+		wg.Add(1)
+		go func(id int, kv KV) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(w.Seed + int64(id)))
+			for n := 0; ctx.Err() == nil; n++ {
+				key := fmt.Sprintf("%s%d", w.Prefix, rnd.Intn(keys))
+				w.do(ctx, rec, id, kv, key, fmt.Sprintf("c%d-%d", id, n), rnd.Float64())
+			}
+		}(i, kv)
+	}
+	wg.Wait()
+	return rec.History()
+}
+
+func (w Workload) do(ctx context.Context, rec *Recorder, id int, kv KV, key, value string, dice float64) {
+	if w.OpTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.OpTimeout)
+		defer cancel()
+	}
+	switch {
+	case dice < w.ReadRatio:
+		call := rec.Invoke(id, Get, key, nil)
+		v, err := kv.Get(ctx, key)
+		call.Complete(v, err)
+	case dice < w.ReadRatio+w.DeleteRatio:
+		call := rec.Invoke(id, Delete, key, nil)
+		call.Complete(nil, kv.Delete(ctx, key))
+	default:
+		call := rec.Invoke(id, Put, key, []byte(value))
+		call.Complete(nil, kv.Put(ctx, key, []byte(value)))
+	}
+}
+
+type commitKV struct {
+	c *client.CommitClient
+}
+
+//FromClient把连接集群的client.CommitClient包装成KV
+func FromClient(c *client.CommitClient) KV {
+	return commitKV{c: c}
+}
+
+func (kv commitKV) Put(ctx context.Context, key string, value []byte) error {
+	return acked(kv.c.Put(ctx, key, value))
+}
+
+func (kv commitKV) Delete(ctx context.Context, key string) error {
+	return acked(kv.c.Delete(ctx, key))
+}
+
+func (kv commitKV) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := kv.c.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return v.Value, nil
+}
+
+//没有错误但也没有ACK的写入不知道有没有生效
+func acked(resp *pb.Response, err error) error {
+	if err == nil && resp.Type != pb.Type_ACK {
+		return status.Errorf(codes.Unknown, "write not acknowledged: %s", resp.Type)
+	}
+	return err
+}
@@ -25,6 +25,21 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+
+	//运维命令：备份或恢复完直接退出
+	if conf.Backup != "" {
+		if err := s.Backup(conf.Backup); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if conf.Restore != "" {
+		if err := s.Restore(conf.Restore); err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	s.Run()
 	<-ch
 	s.Stop()
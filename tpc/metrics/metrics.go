@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//所有指标都通过expvar导出，Serve之后可以在/debug/vars查看
+var (
+	//coordinator跑过的协议轮数和通过这些轮次写入的Put数，两者的比值就是平均的合并程度
+	Rounds    = expvar.NewInt("tpc_rounds")
+	RoundPuts = expvar.NewInt("tpc_round_puts")
+	//每一轮合并的Put个数
+	BatchSize = NewHistogram("tpc_batch_size", 1, 2, 4, 8, 16, 32, 64, 128, 256)
+	//每一轮从propose到commit完成的耗时(ms)
+	RoundLatency = NewHistogram("tpc_round_latency_ms", 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 5000)
+	//协议日志的写入次数和其中需要fsync的次数，presumed-abort/presumed-commit就是为了减少后者
+	WalWrites       = expvar.NewInt("tpc_wal_writes")
+	WalForcedWrites = expvar.NewInt("tpc_wal_forced_writes")
+	//coordinator等待的ack数，presumed变种中不需要ack的决定不计入
+	DecisionAcks = expvar.NewInt("tpc_decision_acks")
+	//参与者上等待key锁的次数、因为锁冲突放弃的次数，以及等待锁的耗时(ms)
+	LockWaits     = expvar.NewInt("tpc_lock_waits")
+	LockConflicts = expvar.NewInt("tpc_lock_conflicts")
+	LockWaitTime  = NewHistogram("tpc_lock_wait_ms", 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 5000)
+)
+
+//Histogram按上界统计落在每个桶里的次数，超过最大上界的记在+Inf里
+type Histogram struct {
+	bounds []float64
+	counts []int64
+	count  int64
+	sum    float64
+	mu     sync.Mutex
+}
+
+func NewHistogram(name string, bounds ...float64) *Histogram {
+	h := &Histogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+	expvar.Publish(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(float64(d) / float64(time.Millisecond))
+}
+
+func (h *Histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	buckets := make([]string, 0, len(h.counts))
+	for i, c := range h.counts {
+		le := "+Inf"
+		if i < len(h.bounds) {
+			le = fmt.Sprintf("%g", h.bounds[i])
+		}
+		buckets = append(buckets, fmt.Sprintf("%q: %d", le, c))
+	}
+	return fmt.Sprintf(`{"count": %d, "sum": %g, "buckets": {%s}}`, h.count, h.sum, strings.Join(buckets, ", "))
+}
+
+//Serve在addr上提供/debug/vars，会一直阻塞
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	return http.ListenAndServe(addr, mux)
+}
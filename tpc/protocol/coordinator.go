@@ -0,0 +1,360 @@
+package protocol
+
+type phase int
+
+const (
+	collecting phase = iota
+	precommitting
+	//已经做出决定，等待参与者确认
+	deciding
+)
+
+type round struct {
+	height       uint64
+	participants []string
+	onePhase     bool
+	phase        phase
+	outcome      Outcome
+	votes        map[string]bool
+	//投了同意票而且不是只读的参与者，precommit和提交只发给它们
+	writers []string
+	//投了READ_ONLY的参与者，回滚也不用通知它们
+	readOnly map[string]bool
+	acks     map[string]bool
+	//决定重发的次数，用来计算退避的间隔
+	resends uint
+	//写过协议日志，结束时要记录end
+	logged bool
+	//重启之后恢复的一阶段提交，没有提案的数据，只能向参与者询问结果
+	recovered bool
+}
+
+func newRound(height uint64, participants []string) *round {
+	return &round{
+		height:       height,
+		participants: participants,
+		votes:        map[string]bool{},
+		readOnly:     map[string]bool{},
+		acks:         map[string]bool{},
+	}
+}
+
+func (r *round) member(p string) bool {
+	for _, q := range r.participants {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+//pending返回需要收到决定的参与者
+func (r *round) pending() []string {
+	if r.outcome == Committed {
+		return r.writers
+	}
+	var ps []string
+	for _, p := range r.participants {
+		if !r.readOnly[p] {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+//Coordinator是server里coordinator的协议部分：Begin开始一轮，收齐投票之后做出决定，把决定发给参与者直到它们确认。
+//需要在崩溃之后留下的状态写在Log里，重启之后从Log恢复
+type Coordinator struct {
+	ID       string
+	Mode     Mode
+	Timeouts Timeouts
+	Log      Log
+
+	epoch  uint64
+	seq    uint64
+	rounds map[Round]*round
+}
+
+func (c *Coordinator) Start(env Env) error {
+	c.rounds = map[Round]*round{}
+	c.epoch, c.seq = 0, 0
+	var order []Round
+	recs := map[Round][]Record{}
+	for _, r := range c.Log.Records() {
+		if r.Type == Boot {
+			if r.Round.Epoch > c.epoch {
+				c.epoch = r.Round.Epoch
+			}
+			continue
+		}
+		if _, ok := recs[r.Round]; !ok {
+			order = append(order, r.Round)
+		}
+		recs[r.Round] = append(recs[r.Round], r)
+	}
+	//每次启动用新的epoch，之后开始的轮次都比崩溃之前的大
+	c.epoch++
+	if err := c.Log.Append(Record{Type: Boot, Round: Round{Epoch: c.epoch}}, true); err != nil {
+		return err
+	}
+	for _, id := range order {
+		var collecting, decision *Record
+		ended := false
+		for i, rec := range recs[id] {
+			switch rec.Type {
+			case Collecting:
+				collecting = &recs[id][i]
+			case Decision:
+				decision = &recs[id][i]
+			case End:
+				ended = true
+			}
+		}
+		switch {
+		case ended:
+		case decision != nil && decision.OnePhase:
+			//参与者投票时已经提交，只需要重新报告
+			env.Decided(id, decision.Height, decision.Outcome)
+			c.Log.Append(Record{Type: End, Round: id, Height: decision.Height, OnePhase: true}, false)
+		case decision != nil:
+			//已经做出的决定重新报告一次，再发给还没有确认的参与者
+			r := newRound(decision.Height, decision.Participants)
+			r.phase, r.outcome, r.logged = deciding, decision.Outcome, true
+			r.writers = decision.Participants
+			c.rounds[id] = r
+			env.Decided(id, r.height, r.outcome)
+			c.send(env, id, r)
+		case collecting.OnePhase:
+			//唯一的参与者可能已经提交了，问到结果为止
+			r := newRound(collecting.Height, collecting.Participants)
+			r.onePhase, r.recovered, r.logged = true, true, true
+			c.rounds[id] = r
+			c.propose(env, id, r)
+		default:
+			//presumed-commit在做出决定之前崩溃，只能回滚
+			r := newRound(collecting.Height, collecting.Participants)
+			r.logged = true
+			c.rounds[id] = r
+			c.decide(env, id, r, Aborted)
+		}
+	}
+	return nil
+}
+
+//NewRound返回下一轮的编号
+func (c *Coordinator) NewRound() Round {
+	c.seq++
+	return Round{Epoch: c.epoch, Seq: c.seq}
+}
+
+//Busy表示这个高度上还有没有做出决定的一轮，比如重启之后还不知道结果的一阶段提交，这时不能在这个高度上开始新的一轮
+func (c *Coordinator) Busy(height uint64) bool {
+	for _, r := range c.rounds {
+		if r.height == height && r.phase != deciding {
+			return true
+		}
+	}
+	return false
+}
+
+//Rounds返回还没有结束的轮次个数，server停止之前等它们把决定送到
+func (c *Coordinator) Rounds() int {
+	return len(c.rounds)
+}
+
+//Begin在height上和participants开始一轮，只有一个参与者时用一阶段提交，没有参与者时直接提交。
+//一阶段提交和presumed-commit在发出提案之前强制记录这一轮，记录失败时直接按回滚报告
+func (c *Coordinator) Begin(env Env, id Round, height uint64, participants []string) {
+	r := newRound(height, participants)
+	r.onePhase = len(participants) == 1
+	if r.onePhase || c.Mode == PresumedCommit {
+		rec := Record{Type: Collecting, Round: id, Height: height, Participants: participants, OnePhase: r.onePhase}
+		if err := c.Log.Append(rec, true); err != nil {
+			env.Decided(id, height, Aborted)
+			return
+		}
+		r.logged = true
+	}
+	c.rounds[id] = r
+	c.propose(env, id, r)
+	c.collected(env, id, r)
+}
+
+func (c *Coordinator) propose(env Env, id Round, r *round) {
+	kind := Propose
+	if r.recovered {
+		kind = Resolve
+	}
+	for _, p := range r.participants {
+		if !r.votes[p] {
+			env.Send(Message{Kind: kind, From: c.ID, To: p, Round: id, Height: r.height, OnePhase: r.onePhase})
+		}
+	}
+	env.After(c.Timeouts.Vote, Timer{Kind: VoteTimeout, Round: id})
+}
+
+//collected在收齐投票之后进入下一个阶段：presumed变种和全部只读时直接提交，2PC和3PC先precommit
+func (c *Coordinator) collected(env Env, id Round, r *round) {
+	if len(r.votes) < len(r.participants) {
+		return
+	}
+	if c.Mode.presumed() || len(r.writers) == 0 {
+		c.decide(env, id, r, Committed)
+		return
+	}
+	r.phase = precommitting
+	r.acks = map[string]bool{}
+	for _, p := range r.writers {
+		env.Send(Message{Kind: Precommit, From: c.ID, To: p, Round: id, Height: r.height})
+	}
+	env.After(c.Timeouts.Precommit, Timer{Kind: PrecommitTimeout, Round: id})
+}
+
+func (c *Coordinator) decide(env Env, id Round, r *round, o Outcome) {
+	//提交总是强制落盘，presumed-commit的回滚也要落盘
+	if o == Committed || c.Mode == PresumedCommit {
+		r.outcome = o
+		rec := Record{Type: Decision, Round: id, Height: r.height, Outcome: o, Participants: r.pending(), OnePhase: r.onePhase}
+		err := c.Log.Append(rec, true)
+		switch {
+		case err == nil:
+			r.logged = true
+		case o == Committed && !r.onePhase:
+			//提交的决定没有落盘就不算做出，改成回滚；一阶段提交的参与者已经决定了，只能接受
+			c.decide(env, id, r, Aborted)
+			return
+		}
+	}
+	r.phase, r.outcome = deciding, o
+	env.Decided(id, r.height, o)
+	if r.onePhase {
+		c.end(id, r)
+		return
+	}
+	r.acks = map[string]bool{}
+	c.send(env, id, r)
+	//presumed-abort的回滚和presumed-commit的提交按推定的结果处理，不等确认
+	if len(r.pending()) == 0 || (o == Aborted && c.Mode == PresumedAbort) || (o == Committed && c.Mode == PresumedCommit) {
+		c.end(id, r)
+	}
+}
+
+func (c *Coordinator) send(env Env, id Round, r *round) {
+	kind := Commit
+	if r.outcome == Aborted {
+		kind = Abort
+	}
+	for _, p := range r.pending() {
+		if !r.acks[p] {
+			env.Send(Message{Kind: kind, From: c.ID, To: p, Round: id, Height: r.height})
+		}
+	}
+	d := c.Timeouts.Resend << r.resends
+	if d < c.Timeouts.MaxResend {
+		r.resends++
+	} else {
+		d = c.Timeouts.MaxResend
+	}
+	env.After(d, Timer{Kind: Resend, Round: id})
+}
+
+func (c *Coordinator) end(id Round, r *round) {
+	delete(c.rounds, id)
+	if r.logged {
+		c.Log.Append(Record{Type: End, Round: id, Height: r.height, OnePhase: r.onePhase}, false)
+	}
+}
+
+func (c *Coordinator) Handle(env Env, m Message) {
+	r := c.rounds[m.Round]
+	switch m.Kind {
+	case Vote:
+		if r == nil || r.phase != collecting || !r.member(m.From) || r.votes[m.From] {
+			return
+		}
+		switch {
+		case r.onePhase:
+			//唯一的参与者投票的同时已经提交或者回滚，不用再通知它
+			o := Aborted
+			if m.Yes {
+				o = Committed
+			}
+			c.decide(env, m.Round, r, o)
+		case !m.Yes:
+			c.decide(env, m.Round, r, Aborted)
+		default:
+			r.votes[m.From] = true
+			if m.ReadOnly {
+				r.readOnly[m.From] = true
+			} else {
+				r.writers = append(r.writers, m.From)
+			}
+			c.collected(env, m.Round, r)
+		}
+	case PrecommitAck:
+		if r == nil || r.phase != precommitting || r.acks[m.From] {
+			return
+		}
+		r.acks[m.From] = true
+		if len(r.acks) == len(r.writers) {
+			c.decide(env, m.Round, r, Committed)
+		}
+	case Failed:
+		//提案和precommit没有回应时回滚；一阶段提交不知道参与者有没有提交，等VoteTimeout重发；决定没有送到时等Resend重发
+		if r == nil || r.onePhase {
+			return
+		}
+		if (m.Request == Propose && r.phase == collecting) || (m.Request == Precommit && r.phase == precommitting) {
+			c.decide(env, m.Round, r, Aborted)
+		}
+	case Ack:
+		if r == nil || r.phase != deciding || r.acks[m.From] {
+			return
+		}
+		r.acks[m.From] = true
+		if len(r.acks) == len(r.pending()) {
+			c.end(m.Round, r)
+		}
+	case Query:
+		//已经忘掉的轮次按推定的结果回答
+		o := Aborted
+		switch {
+		case r != nil:
+			o = r.outcome
+		case c.Mode == PresumedCommit:
+			o = Committed
+		}
+		switch o {
+		case Committed:
+			env.Send(Message{Kind: Commit, From: c.ID, To: m.From, Round: m.Round, Height: m.Height})
+		case Aborted:
+			env.Send(Message{Kind: Abort, From: c.ID, To: m.From, Round: m.Round, Height: m.Height})
+		}
+	}
+}
+
+func (c *Coordinator) Fire(env Env, t Timer) {
+	r := c.rounds[t.Round]
+	if r == nil {
+		return
+	}
+	switch t.Kind {
+	case VoteTimeout:
+		switch {
+		case r.phase != collecting:
+		case r.onePhase:
+			//不知道唯一的参与者有没有提交，重发提案，它对处理过的提案给出同样的票
+			c.propose(env, t.Round, r)
+		default:
+			c.decide(env, t.Round, r, Aborted)
+		}
+	case PrecommitTimeout:
+		if r.phase == precommitting {
+			c.decide(env, t.Round, r, Aborted)
+		}
+	case Resend:
+		if r.phase == deciding {
+			c.send(env, t.Round, r)
+		}
+	}
+}
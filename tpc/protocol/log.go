@@ -0,0 +1,58 @@
+package protocol
+
+type RecordType int
+
+const (
+	//presumed-commit的coordinator在发出提案之前记录参与者，一阶段提交也记录，重启之后要问出结果
+	Collecting RecordType = iota
+	//presumed变种的参与者投同意票之前记录
+	Prepared
+	Decision
+	//coordinator收齐确认之后记录，之后可以忘掉这一轮
+	End
+	//coordinator每次启动记录新的epoch
+	Boot
+)
+
+type Record struct {
+	Type    RecordType
+	Round   Round
+	Height  uint64
+	Outcome Outcome
+	//Collecting记录这一轮的参与者，coordinator的Decision记录需要收到决定的参与者
+	Participants []string
+	OnePhase     bool
+	//参与者的记录里发起这一轮的coordinator，向它询问结果
+	Coordinator string
+}
+
+//Log是节点崩溃之后还留下的状态，server里是协议日志和数据库（2PC和3PC的提交记录就是写进数据库的数据），sim里是MemLog
+type Log interface {
+	//force的记录落盘之后才返回，其余的和下一条force的记录一起落盘，崩溃时可能丢失
+	Append(r Record, force bool) error
+	//Start时返回崩溃之前留下的记录
+	Records() []Record
+}
+
+//MemLog是内存里的Log，Crash丢掉还没有落盘的记录
+type MemLog struct {
+	records []Record
+	pending []Record
+}
+
+func (l *MemLog) Append(r Record, force bool) error {
+	l.pending = append(l.pending, r)
+	if force {
+		l.records = append(l.records, l.pending...)
+		l.pending = nil
+	}
+	return nil
+}
+
+func (l *MemLog) Crash() {
+	l.pending = nil
+}
+
+func (l *MemLog) Records() []Record {
+	return append(append([]Record(nil), l.records...), l.pending...)
+}
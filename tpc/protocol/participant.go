@@ -0,0 +1,257 @@
+package protocol
+
+type state int
+
+const (
+	//投了同意票，等待coordinator的决定
+	proposed state = iota
+	precommitted
+	//投了READ_ONLY，这一轮的决定和它无关
+	readOnly
+	decided
+)
+
+type branch struct {
+	state   state
+	outcome Outcome
+	height  uint64
+	yes     bool
+	//一阶段提交没有可以询问的结果，决定都要落盘
+	onePhase bool
+	//发起这一轮的coordinator，presumed变种向它询问结果
+	coordinator string
+	//投票时随票发回的数据，重复的提案原样发回
+	data interface{}
+}
+
+//Ballot是参与者对一个提案的投票
+type Ballot struct {
+	Yes, ReadOnly bool
+	Data          interface{}
+}
+
+//Participant是server里参与者的协议部分：投票，按coordinator的决定提交或者回滚。
+//presumed变种投票之前写prepared记录，之后迟迟没有收到决定时向coordinator询问
+type Participant struct {
+	ID       string
+	Mode     Mode
+	Timeouts Timeouts
+	//决定是否同意一个提案，server里是hook、只读判断和XA PREPARE
+	Vote func(m Message) Ballot
+	//Resolve时判断一个没有记录的一阶段提交是否已经提交过，server里按数据库里的高度判断，为nil时按没有提交处理
+	Committed func(m Message) bool
+	Log       Log
+
+	branches map[Round]*branch
+	//每个高度上的轮次，按收到的顺序
+	heights map[uint64][]Round
+}
+
+func (p *Participant) Start(env Env) error {
+	p.branches = map[Round]*branch{}
+	p.heights = map[uint64][]Round{}
+	var order []Round
+	for _, r := range p.Log.Records() {
+		b, ok := p.branches[r.Round]
+		if !ok {
+			b = &branch{height: r.Height, yes: true, coordinator: r.Coordinator}
+			p.add(r.Round, b)
+			order = append(order, r.Round)
+		}
+		if r.Type == Decision {
+			b.state, b.outcome = decided, r.Outcome
+		}
+	}
+	for _, id := range order {
+		b := p.branches[id]
+		if b.state == decided {
+			//重启之前的决定可能还没有写进数据库
+			env.Decided(id, b.height, b.outcome)
+		} else {
+			env.After(p.Timeouts.Inquire, Timer{Kind: Inquire, Round: id})
+		}
+	}
+	return nil
+}
+
+func (p *Participant) add(id Round, b *branch) {
+	p.branches[id] = b
+	p.heights[b.height] = append(p.heights[b.height], id)
+}
+
+//Forget忘掉height之前已经有结果的轮次，server在高度推进之后调用，来晚的旧提案由server按高度拒绝
+func (p *Participant) Forget(height uint64) {
+	for h, ids := range p.heights {
+		if h >= height {
+			continue
+		}
+		keep := ids[:0]
+		for _, id := range ids {
+			if b := p.branches[id]; b.state == decided || b.state == readOnly {
+				delete(p.branches, id)
+				continue
+			}
+			keep = append(keep, id)
+		}
+		if len(keep) == 0 {
+			delete(p.heights, h)
+		} else {
+			p.heights[h] = keep
+		}
+	}
+}
+
+//Voted表示这一轮的提案已经处理过，重复的提案回复同样的票，不会再调用Vote
+func (p *Participant) Voted(id Round) bool {
+	_, ok := p.branches[id]
+	return ok
+}
+
+func (p *Participant) Handle(env Env, m Message) {
+	switch m.Kind {
+	case Propose:
+		p.propose(env, m)
+	case Resolve:
+		p.resolve(env, m)
+	case Precommit:
+		//和server一样总是确认，3PC开始等待自动提交
+		if b := p.branches[m.Round]; b != nil && b.state == proposed {
+			b.state = precommitted
+			if p.Mode == ThreePhase {
+				env.After(p.Timeouts.AutoCommit, Timer{Kind: AutoCommit, Round: m.Round})
+			}
+		}
+		env.Send(Message{Kind: PrecommitAck, From: p.ID, To: m.From, Round: m.Round, Height: m.Height})
+	case Commit, Abort:
+		o := Committed
+		if m.Kind == Abort {
+			o = Aborted
+		}
+		b, ok := p.branches[m.Round]
+		switch {
+		case ok && b.state == readOnly:
+		case ok:
+			if !p.decide(env, m.Round, b, o) {
+				//没有记下决定时不确认，coordinator会重发
+				return
+			}
+		case o == Aborted || !p.Mode.presumed():
+			//没有提案的2PC和3PC参与者收到提交时从coordinator追数据；回滚也要记住，来晚的提案投反对票，
+			//否则presumed-commit的coordinator忘掉这一轮之后会把它当成提交
+			b = &branch{height: m.Height, coordinator: m.From}
+			p.add(m.Round, b)
+			if !p.decide(env, m.Round, b, o) {
+				return
+			}
+		}
+		env.Send(Message{Kind: Ack, From: p.ID, To: m.From, Round: m.Round, Height: m.Height})
+	}
+}
+
+func (p *Participant) propose(env Env, m Message) {
+	if b, ok := p.branches[m.Round]; ok {
+		//重复的提案回复同样的票
+		p.vote(env, m, b)
+		return
+	}
+	b := &branch{height: m.Height, coordinator: m.From, onePhase: m.OnePhase}
+	for _, id := range p.heights[m.Height] {
+		if m.Round.Less(id) {
+			//同一个高度上已经有更新的一轮，coordinator已经放弃了这一轮
+			p.add(m.Round, b)
+			p.decide(env, m.Round, b, Aborted)
+			p.vote(env, m, b)
+			return
+		}
+	}
+	//coordinator在这个高度上开始了新的一轮，之前还没有决定的轮次都已经被放弃
+	for _, id := range p.heights[m.Height] {
+		if other := p.branches[id]; other.state == proposed || other.state == precommitted {
+			p.decide(env, id, other, Aborted)
+		}
+	}
+	p.add(m.Round, b)
+	ballot := p.Vote(m)
+	b.yes, b.data = ballot.Yes, ballot.Data
+	switch {
+	case !ballot.Yes:
+		p.decide(env, m.Round, b, Aborted)
+	case m.OnePhase:
+		if !p.decide(env, m.Round, b, Committed) {
+			b.yes = false
+			p.decide(env, m.Round, b, Aborted)
+		}
+	case ballot.ReadOnly:
+		b.state = readOnly
+	case p.Mode.presumed():
+		rec := Record{Type: Prepared, Round: m.Round, Height: m.Height, Coordinator: m.From}
+		if err := p.Log.Append(rec, true); err != nil {
+			b.yes, b.data = false, err
+			p.decide(env, m.Round, b, Aborted)
+			break
+		}
+		env.After(p.Timeouts.Inquire, Timer{Kind: Inquire, Round: m.Round})
+	}
+	p.vote(env, m, b)
+}
+
+//resolve回答重启之后的coordinator对一阶段提交的询问：处理过的提案给出同样的票，没有见过的以后也不能再提交
+func (p *Participant) resolve(env Env, m Message) {
+	b, ok := p.branches[m.Round]
+	if !ok {
+		b = &branch{height: m.Height, coordinator: m.From, onePhase: true}
+		p.add(m.Round, b)
+		if p.Committed != nil && p.Committed(m) {
+			b.yes, b.state, b.outcome = true, decided, Committed
+		} else {
+			p.decide(env, m.Round, b, Aborted)
+		}
+	}
+	p.vote(env, m, b)
+}
+
+func (p *Participant) vote(env Env, m Message, b *branch) {
+	yes := b.yes && (b.state != decided || b.outcome == Committed)
+	env.Send(Message{
+		Kind:     Vote,
+		From:     p.ID,
+		To:       m.From,
+		Round:    m.Round,
+		Height:   b.height,
+		Yes:      yes,
+		ReadOnly: yes && b.state == readOnly,
+		Data:     b.data,
+	})
+}
+
+//decide记下决定再报告给Env，记录失败时返回false
+func (p *Participant) decide(env Env, id Round, b *branch, o Outcome) bool {
+	if b.state == decided {
+		return true
+	}
+	//推定的结果不用强制落盘，丢了之后可以问出同样的结果
+	force := b.onePhase || !(p.Mode == PresumedAbort && o == Aborted) && !(p.Mode == PresumedCommit && o == Committed)
+	rec := Record{Type: Decision, Round: id, Height: b.height, Outcome: o, Coordinator: b.coordinator}
+	if err := p.Log.Append(rec, force); err != nil {
+		return false
+	}
+	b.state, b.outcome = decided, o
+	env.Decided(id, b.height, o)
+	return true
+}
+
+func (p *Participant) Fire(env Env, t Timer) {
+	b := p.branches[t.Round]
+	if b == nil || (b.state != proposed && b.state != precommitted) {
+		return
+	}
+	switch t.Kind {
+	case AutoCommit:
+		if b.state == precommitted {
+			p.decide(env, t.Round, b, Committed)
+		}
+	case Inquire:
+		env.Send(Message{Kind: Query, From: p.ID, To: b.coordinator, Round: t.Round, Height: b.height})
+		env.After(p.Timeouts.Inquire, Timer{Kind: Inquire, Round: t.Round})
+	}
+}
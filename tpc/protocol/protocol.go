@@ -0,0 +1,206 @@
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Mode对应server里的committype
+type Mode int
+
+const (
+	TwoPhase Mode = iota
+	ThreePhase
+	PresumedAbort
+	PresumedCommit
+)
+
+var modeNames = []string{"two-phase", "three-phase", "presumed-abort", "presumed-commit"}
+
+func ParseMode(name string) (Mode, error) {
+	for i, n := range modeNames {
+		if n == name {
+			return Mode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown commit type %q", name)
+}
+
+func (m Mode) String() string {
+	return modeNames[m]
+}
+
+func (m Mode) presumed() bool {
+	return m == PresumedAbort || m == PresumedCommit
+}
+
+type Kind int
+
+const (
+	Propose Kind = iota
+	Vote
+	Precommit
+	PrecommitAck
+	Commit
+	Abort
+	Ack
+	//参与者向coordinator询问不确定的事务的结果
+	Query
+	//重启之后的coordinator向参与者询问一阶段提交的结果，参与者回复Vote
+	Resolve
+	//Env报告一条请求没有得到回应（连接失败、超时），对方可能收到了也可能没有
+	Failed
+)
+
+var kindNames = []string{"propose", "vote", "precommit", "precommit-ack", "commit", "abort", "ack", "query", "resolve", "failed"}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+//Round标识一轮协议：Epoch是coordinator启动的次数，Seq是这次启动之后的序号，
+//同一个高度上后开始的一轮总是更大，参与者据此丢掉被取代的轮次
+type Round struct {
+	Epoch, Seq uint64
+}
+
+func (r Round) Less(o Round) bool {
+	if r.Epoch != o.Epoch {
+		return r.Epoch < o.Epoch
+	}
+	return r.Seq < o.Seq
+}
+
+func (r Round) String() string {
+	return fmt.Sprintf("%d.%d", r.Epoch, r.Seq)
+}
+
+//ParseRound解析Round.String的结果，server把它作为请求里的事务id
+func ParseRound(s string) (Round, error) {
+	i := strings.IndexByte(s, '.')
+	if i < 0 {
+		return Round{}, fmt.Errorf("invalid round %q", s)
+	}
+	epoch, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return Round{}, fmt.Errorf("invalid round %q", s)
+	}
+	seq, err := strconv.ParseUint(s[i+1:], 10, 64)
+	if err != nil {
+		return Round{}, fmt.Errorf("invalid round %q", s)
+	}
+	return Round{Epoch: epoch, Seq: seq}, nil
+}
+
+//Message是节点之间的一条协议消息
+type Message struct {
+	Kind     Kind
+	From, To string
+	Round    Round
+	//这一轮写入的高度，同一个高度上回滚的轮次会被下一轮取代
+	Height uint64
+	//Vote时表示是否同意，ReadOnly表示参与者的状态不会改变，不参加后面的阶段
+	Yes, ReadOnly bool
+	//只有一个参与者，它投票的同时提交
+	OnePhase bool
+	//Failed时是没有回应的那条请求的类型
+	Request Kind
+	//Env自己的数据，server里是提案的内容和拒绝的原因，协议不解释它
+	Data interface{}
+}
+
+func (m Message) String() string {
+	s := fmt.Sprintf("%s -> %s %s r%s h%d", m.From, m.To, m.Kind, m.Round, m.Height)
+	switch {
+	case m.Kind == Vote && m.ReadOnly:
+		s += " read-only"
+	case m.Kind == Vote && m.Yes:
+		s += " yes"
+	case m.Kind == Vote:
+		s += " no"
+	case m.Kind == Failed:
+		s += " " + m.Request.String()
+	}
+	if m.OnePhase {
+		s += " one-phase"
+	}
+	return s
+}
+
+type TimerKind int
+
+const (
+	//coordinator等待投票超时
+	VoteTimeout TimerKind = iota
+	//coordinator等待precommit的确认超时
+	PrecommitTimeout
+	//coordinator重发还没有确认的决定
+	Resend
+	//3PC的参与者precommit之后超时自动提交
+	AutoCommit
+	//presumed变种里处于不确定状态的参与者定期询问结果
+	Inquire
+)
+
+var timerNames = []string{"vote-timeout", "precommit-timeout", "resend", "autocommit", "inquire"}
+
+func (k TimerKind) String() string {
+	return timerNames[k]
+}
+
+type Timer struct {
+	Kind  TimerKind
+	Round Round
+}
+
+func (t Timer) String() string {
+	return fmt.Sprintf("%s r%s", t.Kind, t.Round)
+}
+
+type Outcome int
+
+const (
+	Undecided Outcome = iota
+	Committed
+	Aborted
+)
+
+func (o Outcome) String() string {
+	return [...]string{"undecided", "committed", "aborted"}[o]
+}
+
+//Env是协议运行的环境：发送消息、设置定时器和报告决定。server里消息是gRPC调用，定时器是time.AfterFunc；
+//sim里是模拟的网络和时钟。消息可能丢失、重复、乱序，节点必须能处理
+type Env interface {
+	Send(m Message)
+	After(d time.Duration, t Timer)
+	//节点提交或者回滚了某一轮：参与者写入或者丢掉数据，coordinator做出决定；重启之后按日志再报告一次
+	Decided(round Round, height uint64, o Outcome)
+}
+
+//Node是一个协议节点，server的coordinator和参与者就是Coordinator和Participant。
+//Start在启动和崩溃重启之后调用，之前的内存状态都已经丢失，只剩下Log；所有方法都不能并发调用
+type Node interface {
+	Start(env Env) error
+	Handle(env Env, m Message)
+	Fire(env Env, t Timer)
+}
+
+type Timeouts struct {
+	Vote, Precommit, AutoCommit, Inquire time.Duration
+	//决定没有确认时从Resend开始按指数退避重发，最长间隔MaxResend
+	Resend, MaxResend time.Duration
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Vote:       time.Second,
+		Precommit:  time.Second,
+		AutoCommit: time.Second,
+		Inquire:    time.Second,
+		Resend:     100 * time.Millisecond,
+		MaxResend:  time.Second,
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/metrics"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+const (
+	//默认一轮最多合并的Put个数
+	defaultBatchSize = 64
+	//同时进行的轮次上限
+	defaultMaxRounds = 8
+)
+
+type proposal struct {
+	entry *pb.Entry
+	ctx   context.Context
+	done  chan roundResult
+	//settle在这个Put有了最终结果时调用，不管调用方是否还在等
+	settle func(roundResult)
+}
+
+func (p *proposal) resolve(r roundResult) {
+	p.done <- r
+	if p.settle != nil {
+		p.settle(r)
+	}
+}
+
+type roundResult struct {
+	resp    *pb.Response
+	decided bool
+	err     error
+}
+
+//batcher把并发的Put合并成一轮propose/precommit/commit，最多同时跑defaultMaxRounds轮，
+//轮次都在进行中时到达的Put会进入下一轮，linger不为0时每一轮开始前再多等一会儿凑批
+type batcher struct {
+	queue  chan *proposal
+	size   int
+	linger time.Duration
+	round  func(msgs []cache.Msg) roundResult
+	//每个进行中的轮次占一个位置
+	slots  chan struct{}
+	stopCh chan struct{}
+}
+
+func newBatcher(size int, linger time.Duration, round func(msgs []cache.Msg) roundResult, stopCh chan struct{}) *batcher {
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	return &batcher{
+		queue:  make(chan *proposal, size),
+		size:   size,
+		linger: linger,
+		round:  round,
+		slots:  make(chan struct{}, defaultMaxRounds),
+		stopCh: stopCh,
+	}
+}
+
+//abandoned是调用方在进入某一轮之前就放弃的Put的结果，它一定没有写入
+func abandoned(ctx context.Context) roundResult {
+	code := codes.Canceled
+	if ctx.Err() == context.DeadlineExceeded {
+		code = codes.DeadlineExceeded
+	}
+	return roundResult{err: rolledBack(status.Error(code, ctx.Err().Error()))}
+}
+
+//submit提交一个Put并等待它所在那一轮的结果，settle可以为nil
+func (b *batcher) submit(ctx context.Context, entry *pb.Entry, settle func(roundResult)) roundResult {
+	p := &proposal{entry: entry, ctx: ctx, done: make(chan roundResult, 1), settle: settle}
+	select {
+	case b.queue <- p:
+	case <-ctx.Done():
+		r := abandoned(ctx)
+		p.resolve(r)
+		return r
+	}
+	select {
+	case r := <-p.done:
+		return r
+	case <-ctx.Done():
+		//这一轮仍然可能提交，结果不确定，settle会在这一轮结束之后拿到真正的结果
+		return roundResult{err: ctx.Err()}
+	}
+}
+
+func (b *batcher) run() {
+	for {
+		//先占住位置再凑批，轮次都在进行中时Put在队列里攒成下一批
+		select {
+		case b.slots <- struct{}{}:
+		case <-b.stopCh:
+			return
+		}
+		select {
+		case p := <-b.queue:
+			batch := b.collect(p)
+			go func() {
+				defer func() { <-b.slots }()
+				b.commit(batch)
+			}()
+		case <-b.stopCh:
+			return
+		}
+	}
+}
+
+func (b *batcher) collect(first *proposal) []*proposal {
+	batch := []*proposal{first}
+	var linger <-chan time.Time
+	if b.linger > 0 {
+		t := time.NewTimer(b.linger)
+		defer t.Stop()
+		linger = t.C
+	}
+	for len(batch) < b.size {
+		if linger == nil {
+			//不等待，只把已经排队的Put带上
+			select {
+			case p := <-b.queue:
+				batch = append(batch, p)
+				continue
+			default:
+				return batch
+			}
+		}
+		select {
+		case p := <-b.queue:
+			batch = append(batch, p)
+		case <-linger:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (b *batcher) commit(batch []*proposal) {
+	//排队期间已经放弃的调用不再写入
+	live := batch[:0]
+	for _, p := range batch {
+		if p.ctx.Err() == nil {
+			live = append(live, p)
+		} else {
+			p.resolve(abandoned(p.ctx))
+		}
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	msgs := make([]cache.Msg, 0, len(live))
+	for _, p := range live {
+		msgs = append(msgs, cache.Msg{Key: p.entry.Key, Value: p.entry.Value, Delete: p.entry.Delete})
+	}
+	metrics.BatchSize.Observe(float64(len(msgs)))
+	r := b.round(msgs)
+
+	//整批在做出提交决定之前被hook拒绝时逐个重试，避免一个被拒绝的写入连累同一批的其他写入
+	if len(live) > 1 && !r.decided && status.Code(r.err) == codes.FailedPrecondition {
+		for i, p := range live {
+			metrics.BatchSize.Observe(1)
+			p.resolve(b.round(msgs[i : i+1]))
+		}
+		return
+	}
+	for _, p := range live {
+		p.resolve(r)
+	}
+}
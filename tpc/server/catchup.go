@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/client"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/protocol"
+	"github.com/sysphusking/dsts/2pc/snapshot"
+)
+
+//一次追数据最长的时间
+const catchUpTimeout = time.Minute
+
+//follower发现自己落后于coordinator时在后台追数据，同一时间只会有一个在跑
+func (s *Server) catchUp(target uint64) {
+	if s.coordinator == nil {
+		log.Warn(fmt.Sprintf("behind coordinator (height %d < %d) but no coordinator address configured", atomic.LoadUint64(&s.Height), target))
+		return
+	}
+	if s.isDraining() || !atomic.CompareAndSwapInt32(&s.catchingUp, 0, 1) {
+		return
+	}
+	//Stop开始时取消，drain等它退出之后才关闭数据库
+	started := s.goBackground(func() {
+		defer atomic.StoreInt32(&s.catchingUp, 0)
+		ctx, cancel := s.drainContext(catchUpTimeout)
+		defer cancel()
+		if err := s.pull(ctx, s.coordinator, target); err != nil {
+			log.Error(fmt.Sprintf("failed to catch up to height %d: %v", target, err))
+			return
+		}
+		log.Info(fmt.Sprintf("caught up to height %d", atomic.LoadUint64(&s.Height)))
+	})
+	if !started {
+		atomic.StoreInt32(&s.catchingUp, 0)
+	}
+}
+
+//通过coordinator的Watch回放缺失的提交，需要的部分已经被压缩时先安装快照。
+//本节点还在等决定的高度不直接写入，回放结束之后按coordinator有没有写入这个高度交给参与者节点提交或者回滚
+func (s *Server) pull(ctx context.Context, cli *client.CommitClient, target uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := cli.WatchRange(ctx, "", atomic.LoadUint64(&s.Height), target)
+	if err != nil {
+		return err
+	}
+	//同一个高度的事件收齐之后再一起交给settle
+	var pending []*pb.WatchEvent
+	committed := map[uint64]bool{}
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		index, events := pending[0].Index, pending
+		committed[index] = true
+		pending = nil
+		if s.waiting(index) {
+			return
+		}
+		s.settle(index, &settlement{committed: true, apply: func() ([]*pb.WatchEvent, error) {
+			for _, event := range events {
+				m := cache.Msg{Key: event.Key, Value: event.Value, Delete: event.Type == pb.EventType_DELETE}
+				if _, err := apply(s.DB, event.Index, m); err != nil {
+					return nil, err
+				}
+			}
+			return events, nil
+		}})
+	}
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			flush()
+			s.settleTo(target, committed)
+			return nil
+		}
+		if status.Code(err) == codes.OutOfRange {
+			if err := s.fetchSnapshot(ctx, cli); err != nil {
+				return err
+			}
+			if stream, err = cli.WatchRange(ctx, "", atomic.LoadUint64(&s.Height), target); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 && pending[0].Index != event.Index {
+			flush()
+		}
+		pending = append(pending, event)
+	}
+}
+
+//waiting判断本节点在这个高度上有没有投了同意票、还在等决定的轮次
+func (s *Server) waiting(index uint64) bool {
+	p := s.participantNode
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, b := range s.branches {
+		if b.index == index {
+			return true
+		}
+	}
+	return false
+}
+
+//回放正常结束说明coordinator在target之前的提交都已经收到：本节点还在等决定的轮次按coordinator有没有写入这个高度
+//提交或者回滚，其余没有结果的高度上没有本节点的数据，比如回滚的轮次和只写在登记参与者上的事务，是空洞
+func (s *Server) settleTo(target uint64, committed map[uint64]bool) {
+	p := s.participantNode
+	p.mu.Lock()
+	var decisions []protocol.Message
+	waiting := map[uint64]bool{}
+	for id, b := range s.branches {
+		if b.index >= target {
+			continue
+		}
+		waiting[b.index] = true
+		kind := protocol.Abort
+		if committed[b.index] {
+			kind = protocol.Commit
+		}
+		decisions = append(decisions, protocol.Message{Kind: kind, From: s.Config.Coordinator, To: s.Addr, Round: id, Height: b.index})
+	}
+	for height := atomic.LoadUint64(&s.Height); height < target; height++ {
+		if !waiting[height] && !committed[height] {
+			s.settle(height, &settlement{})
+		}
+	}
+	p.mu.Unlock()
+	for _, m := range decisions {
+		s.deliver(p, m)
+	}
+}
+
+func (s *Server) fetchSnapshot(ctx context.Context, cli *client.CommitClient) error {
+	stream, err := cli.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Config.SnapshotDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.Config.SnapshotDir, "transfer")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			tmp.Close()
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if _, err := tmp.Write(chunk.Data); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	//校验通过后再替换本地数据
+	snap, err := snapshot.Read(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if _, err := snapshot.Write(s.Config.SnapshotDir, snap); err != nil {
+		return err
+	}
+	return s.install(snap)
+}
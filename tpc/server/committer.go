@@ -1,11 +1,8 @@
 package server
 
 import (
+	"bytes"
 	"context"
-	"errors"
-	"fmt"
-
-	log "github.com/sirupsen/logrus"
 
 	"github.com/sysphusking/dsts/2pc/db"
 
@@ -13,42 +10,50 @@ import (
 	pb "github.com/sysphusking/dsts/2pc/proto"
 )
 
-func ProposeHandler(ctx context.Context, req *pb.ProposeRequest, hook func(req *pb.ProposeRequest) bool, nodeCache cache.ICache) (*pb.Response, error) {
-
-	var rsp *pb.Response
-	if hook(req) {
-		log.Info(fmt.Sprintf("Propose Received: %s=%s\n", req.Key, string(req.Value)))
-		nodeCache.Set(req.Index, req.Key, req.Value)
-		rsp = &pb.Response{Type: pb.Type_ACK}
-	} else {
-		rsp = &pb.Response{Type: pb.Type_NACK}
-	}
-	return rsp, nil
-}
-
 func PreCommitHandler(ctx context.Context, req *pb.PrecommitRequest) (*pb.Response, error) {
 	return &pb.Response{
 		Type: pb.Type_ACK,
 	}, nil
 }
 
-func CommitHandler(ctx context.Context, req *pb.CommitRequest, hook func(req *pb.CommitRequest) bool, db db.Database, nodeCache cache.ICache) (*pb.Response, error) {
-	var rsp *pb.Response
-	if hook(req) {
-		log.Info(fmt.Sprintf("Committing on height: %d\n", req.Index))
-		key, value, ok := nodeCache.Get(req.Index)
-		if !ok {
-			nodeCache.Delete(req.Index)
-			return &pb.Response{Type: pb.Type_NACK}, errors.New(fmt.Sprintf("no value in node cache on the index %d", req.Index))
+func msgKeys(msgs []cache.Msg) []string {
+	keys := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		keys = append(keys, m.Key)
+	}
+	return keys
+}
+
+//readOnly判断这些数据写入之后本地的状态是否不变，这时参与者不需要参加后面的阶段
+func readOnly(db db.Database, msgs []cache.Msg) bool {
+	for _, m := range msgs {
+		value, err := db.Get(m.Key)
+		if err != nil {
+			return false
+		}
+		//不存在或者已经删除的key读出来是nil，值为空的key是长度为0的非nil切片：
+		//删除不存在的key，或者Put和现有的值相同（包括空值）都不改变状态
+		if m.Delete {
+			if value != nil {
+				return false
+			}
+			continue
 		}
-		if err := db.Put(key, value); err != nil {
-			return nil, err
+		if value == nil || !bytes.Equal(value, m.Value) {
+			return false
 		}
-		rsp = &pb.Response{Type: pb.Type_ACK}
+	}
+	return true
+}
 
-	} else {
-		nodeCache.Delete(req.Index)
-		rsp = &pb.Response{Type: pb.Type_NACK}
+//单条写入用Key/Value，合并提交时用Entries
+func proposedMsgs(req *pb.ProposeRequest) []cache.Msg {
+	if len(req.Entries) == 0 {
+		return []cache.Msg{{Key: req.Key, Value: req.Value, Delete: req.Delete}}
+	}
+	msgs := make([]cache.Msg, 0, len(req.Entries))
+	for _, e := range req.Entries {
+		msgs = append(msgs, cache.Msg{Key: e.Key, Value: e.Value, Delete: e.Delete})
 	}
-	return rsp, nil
+	return msgs
 }
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//默认去重表最多记住的请求数
+const defaultDedupSize = 10000
+
+//dedupCall是一个带request id的Put，重试的请求会等待并复用第一次的结果
+type dedupCall struct {
+	done chan struct{}
+	resp *pb.Response
+	err  error
+	elem *list.Element
+}
+
+//dedupTable按request id记住Put的结果，容量满了之后淘汰最早的记录
+type dedupTable struct {
+	calls map[string]*dedupCall
+	order *list.List
+	size  int
+	mu    sync.Mutex
+}
+
+func newDedupTable(size int) *dedupTable {
+	if size <= 0 {
+		size = defaultDedupSize
+	}
+	return &dedupTable{calls: map[string]*dedupCall{}, order: list.New(), size: size}
+}
+
+//begin返回id对应的调用，owner为true表示调用方需要真正执行这次Put
+func (d *dedupTable) begin(id string) (call *dedupCall, owner bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if call, ok := d.calls[id]; ok {
+		return call, false
+	}
+	call = &dedupCall{done: make(chan struct{})}
+	call.elem = d.order.PushBack(id)
+	d.calls[id] = call
+	for d.order.Len() > d.size {
+		oldest := d.order.Front()
+		d.order.Remove(oldest)
+		delete(d.calls, oldest.Value.(string))
+	}
+	return call, true
+}
+
+//finish记录结果并唤醒等待的重试，remember为false时删掉记录，之后的重试会重新发起一轮
+func (d *dedupTable) finish(id string, call *dedupCall, resp *pb.Response, err error, remember bool) {
+	d.mu.Lock()
+	call.resp, call.err = resp, err
+	if !remember && d.calls[id] == call {
+		d.order.Remove(call.elem)
+		delete(d.calls, id)
+	}
+	d.mu.Unlock()
+	close(call.done)
+}
+
+func (d *dedupTable) wait(ctx context.Context, call *dedupCall) (*pb.Response, error) {
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
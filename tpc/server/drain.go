@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/2pc/protocol"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errDraining = rolledBack(status.Error(codes.Unavailable, "server is shutting down"))
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+//goBackground启动一个Stop需要等待的后台协程，Stop已经在等最后一批后台协程时不再启动，返回false
+func (s *Server) goBackground(fn func()) bool {
+	s.bgMu.Lock()
+	defer s.bgMu.Unlock()
+	if s.bgClosed {
+		return false
+	}
+	s.bg.Add(1)
+	go func() {
+		defer s.bg.Done()
+		fn()
+	}()
+	return true
+}
+
+//drainContext返回一个Stop开始时就取消的context，追数据这样的后台任务用它，drain不用等它们跑完
+func (s *Server) drainContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	go func() {
+		select {
+		case <-s.drainCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+//waitUntil在deadline之前等待fn返回，超时返回false，fn会继续在后台执行
+func waitUntil(deadline time.Time, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+//drain在deadline之前让节点安静下来：
+//1. 不再接受新的Put、事务和提案，取消还没有触发的3PC自动提交，停止追数据和快照
+//2. 等进行中的轮次做出决定，presumed变种的决定已经在协议日志里
+//3. 等coordinator节点把决定发给参与者，超时的部分由协议日志或者参与者追数据补上
+//4. 停止http网关和gRPC，等进行中的请求返回
+//5. 等所有后台协程退出，之后Stop才能关闭协议日志和数据库
+func (s *Server) drain(deadline time.Time) {
+	if !atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+		return
+	}
+	close(s.drainCh)
+
+	if !waitUntil(deadline, s.waitDecided) {
+		log.Warn(fmt.Sprintf("rounds after height %d are still in progress at shutdown deadline", atomic.LoadUint64(&s.Height)))
+	}
+	if !waitUntil(deadline, s.waitDelivered) {
+		log.Warn("decisions are still being delivered at shutdown deadline, followers will recover them from the wal or by catching up")
+	}
+	//停止后台重试和还没有送到的决定
+	close(s.stopCh)
+
+	if s.httpServer != nil {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			s.httpServer.Close()
+		}
+		cancel()
+	}
+	if s.GrpcServer != nil && !waitUntil(deadline, s.GrpcServer.GracefulStop) {
+		//Watch这样的长连接不会自己结束
+		log.Warn("requests are still in progress at shutdown deadline, closing connections")
+		s.GrpcServer.Stop()
+	}
+
+	//drainCh和stopCh都已经关闭，剩下的后台协程只是在等正在进行的一次调用或者写入
+	s.bgMu.Lock()
+	s.bgClosed = true
+	s.bgMu.Unlock()
+	s.bg.Wait()
+}
+
+//waitDecided等coordinator上进行中的轮次都做出决定，stopCh关闭之后不再等
+func (s *Server) waitDecided() {
+	for {
+		s.roundsMu.Lock()
+		rounds := len(s.rounds)
+		s.roundsMu.Unlock()
+		if rounds == 0 {
+			return
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+//waitDelivered等coordinator节点的轮次都结束、后台的调用都返回，stopCh关闭之后不再等
+func (s *Server) waitDelivered() {
+	for {
+		p := s.coordinatorNode
+		p.mu.Lock()
+		rounds := p.node.(*protocol.Coordinator).Rounds()
+		p.mu.Unlock()
+		if rounds == 0 {
+			s.bg.Wait()
+			return
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-s.stopCh:
+			return
+		}
+	}
+}
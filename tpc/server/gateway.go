@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/client"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//http网关把REST请求转给和gRPC相同的Server方法，value在json里是base64编码（[]byte的默认编码）
+type kvRequest struct {
+	Value     []byte `json:"value"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+type kvResponse struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type writeResponse struct {
+	Index uint64 `json:"index"`
+}
+
+type nodeResponse struct {
+	Addr        string `json:"addr"`
+	Height      uint64 `json:"height"`
+	Coordinator string `json:"coordinator"`
+	Role        string `json:"role"`
+}
+
+type vote struct {
+	Node    string `json:"node"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+	//follower拒绝时的投票结果
+	Votes []vote `json:"votes,omitempty"`
+}
+
+//httpCodes是gRPC错误码对应的http状态码，没有列出的都是500
+var httpCodes = map[codes.Code]int{
+	codes.Canceled:           499,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusPreconditionFailed,
+	codes.Aborted:            http.StatusConflict,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+func (s *Server) gateway() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/kv/", s.handleKV)
+	mux.HandleFunc("/node", s.handleNode)
+	return mux
+}
+
+func (s *Server) handleKV(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/kv/")
+	if key == "" {
+		writeError(w, status.Error(codes.InvalidArgument, "key is empty"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		value, err := s.Get(r.Context(), &pb.Msg{Key: key})
+		if err == nil && !value.Found {
+			err = status.Errorf(codes.NotFound, "key %s not found", key)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, kvResponse{Key: key, Value: value.Value})
+	case http.MethodPut:
+		var req kvRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, status.Errorf(codes.InvalidArgument, "invalid body: %v", err))
+			return
+		}
+		s.write(r.Context(), w, &pb.Entry{Key: key, Value: req.Value, RequestId: req.RequestID})
+	case http.MethodDelete:
+		s.write(r.Context(), w, &pb.Entry{Key: key, Delete: true, RequestId: r.URL.Query().Get("requestId")})
+	default:
+		notAllowed(w, r, "GET, PUT, DELETE")
+	}
+}
+
+func (s *Server) write(ctx context.Context, w http.ResponseWriter, entry *pb.Entry) {
+	resp, err := s.Put(ctx, entry)
+	if err == nil && resp.Type != pb.Type_ACK {
+		err = voteError("put", resp)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, writeResponse{Index: resp.Index})
+}
+
+func (s *Server) handleNode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, r, "GET")
+		return
+	}
+	info, err := s.NodeInfo(r.Context(), &empty.Empty{})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nodeResponse{Addr: s.Addr, Height: info.Height, Coordinator: info.Coordinator, Role: info.Role})
+}
+
+func notAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Code: codes.Unimplemented.String(), Error: "method " + r.Method + " not allowed"})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code, ok := httpCodes[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	resp := errorResponse{Code: st.Code().String(), Error: st.Message()}
+	for _, v := range client.Votes(err) {
+		resp.Votes = append(resp.Votes, vote{Node: v.Node, Reason: strings.ToLower(v.Reason.String()), Message: v.Message})
+	}
+	writeJSON(w, code, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn("failed to write http response: ", err)
+	}
+}
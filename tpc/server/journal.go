@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//每个节点最多保留这么多个高度的协议事件，更早的按高度从小到大丢掉
+const journalHeights = 4096
+
+//journal按高度记录这个节点上的协议事件：提案、投票、预提交、提交、回滚和超时
+type journal struct {
+	node   string
+	events map[uint64][]*pb.JournalEvent
+	//记录过事件的高度，按第一次记录的顺序
+	order []uint64
+	mu    sync.Mutex
+}
+
+func newJournal(node string) *journal {
+	return &journal{node: node, events: map[uint64][]*pb.JournalEvent{}}
+}
+
+func (j *journal) record(stage pb.JournalEvent_Stage, index uint64, peer, txid, message string) {
+	event := &pb.JournalEvent{
+		Stage:     stage,
+		Index:     index,
+		Peer:      peer,
+		Timestamp: time.Now().UnixNano(),
+		Txid:      txid,
+		Message:   message,
+		Node:      j.node,
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.events[index]; !ok {
+		j.order = append(j.order, index)
+		if len(j.order) > journalHeights {
+			delete(j.events, j.order[0])
+			j.order = j.order[1:]
+		}
+	}
+	j.events[index] = append(j.events[index], event)
+}
+
+func (j *journal) get(index uint64) []*pb.JournalEvent {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]*pb.JournalEvent(nil), j.events[index]...)
+}
+
+//voteMessage把一次回应写成事件的说明，比如“NACK hook_rejected: ...”
+func voteMessage(resp *pb.Response) string {
+	if resp == nil {
+		return "no response"
+	}
+	if resp.Type != pb.Type_NACK {
+		return resp.Type.String()
+	}
+	return fmt.Sprintf("NACK %s: %s", strings.ToLower(resp.Reason.String()), resp.Message)
+}
+
+//peerOf返回发来请求的节点地址，参与者用它记录事件的对端
+func peerOf(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+//recordCall记录一次发给follower的调用的结果，超时单独记成TIMEOUT_FIRED
+func (s *Server) recordCall(stage pb.JournalEvent_Stage, index uint64, follower string, txid string, resp *pb.Response, err error) {
+	if err != nil {
+		if status.Code(err) == codes.DeadlineExceeded {
+			s.journal.record(pb.JournalEvent_TIMEOUT_FIRED, index, follower, txid, fmt.Sprintf("%s timed out", strings.ToLower(stage.String())))
+			return
+		}
+		s.journal.record(stage, index, follower, txid, err.Error())
+		return
+	}
+	s.journal.record(stage, index, follower, txid, voteMessage(resp))
+}
+
+func (s *Server) Journal(ctx context.Context, request *pb.JournalRequest) (*pb.JournalResponse, error) {
+	return &pb.JournalResponse{Events: s.journal.get(request.Index)}, nil
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sysphusking/dsts/2pc/metrics"
+)
+
+const (
+	//老事务等待持有锁的新事务，新事务直接放弃，不会出现死锁
+	WAIT_DIE = "wait-die"
+	//遇到冲突立刻放弃
+	NO_WAIT = "no-wait"
+)
+
+var (
+	errLockConflict = errors.New("key is locked by another transaction")
+	errLockTimeout  = errors.New("timed out waiting for key lock")
+)
+
+type keyLock struct {
+	owner string
+	ts    uint64
+	//锁释放时关闭
+	released chan struct{}
+	//持有者已经决定提交、在等前面的高度写入时关闭，更早的高度等不到这把锁，直接放弃
+	committed chan struct{}
+	closed    bool
+}
+
+//lockTable是参与者上的key锁，propose时加锁，回滚或者提交的高度写入之后释放，不冲突的事务可以同时进行，
+//写同一个key的事务按高度的顺序提交
+type lockTable struct {
+	policy  string
+	timeout time.Duration
+	locks   map[string]*keyLock
+	//每个事务持有的key
+	holders map[string][]string
+	mu      sync.Mutex
+}
+
+func newLockTable(policy string, timeout time.Duration) *lockTable {
+	if policy != NO_WAIT {
+		policy = WAIT_DIE
+	}
+	return &lockTable{
+		policy:  policy,
+		timeout: timeout,
+		locks:   map[string]*keyLock{},
+		holders: map[string][]string{},
+	}
+}
+
+//acquire按key的顺序加锁，ts越小的事务越老，失败时释放这个事务已经持有的所有锁
+func (t *lockTable) acquire(owner string, ts uint64, keys []string) error {
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		if err := t.lock(owner, ts, key); err != nil {
+			metrics.LockConflicts.Add(1)
+			t.release(owner)
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *lockTable) lock(owner string, ts uint64, key string) error {
+	var (
+		deadline <-chan time.Time
+		start    time.Time
+	)
+	for {
+		t.mu.Lock()
+		l, ok := t.locks[key]
+		if !ok || l.owner == owner {
+			if !ok {
+				t.locks[key] = &keyLock{owner: owner, ts: ts, released: make(chan struct{}), committed: make(chan struct{})}
+				t.holders[owner] = append(t.holders[owner], key)
+			}
+			t.mu.Unlock()
+			if deadline != nil {
+				metrics.LockWaitTime.ObserveDuration(time.Since(start))
+			}
+			return nil
+		}
+		committed := l.committed
+		if l.closed {
+			committed = nil
+		}
+		t.mu.Unlock()
+
+		//持有者已经决定提交时，锁在更早的高度都写入之后释放：更新的事务可以等，更老的事务等不到
+		wait := ts < l.ts && committed != nil || ts > l.ts && committed == nil
+		if t.policy == NO_WAIT || !wait {
+			return errLockConflict
+		}
+		if deadline == nil {
+			metrics.LockWaits.Add(1)
+			start = time.Now()
+			timer := time.NewTimer(t.timeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		select {
+		case <-l.released:
+		case <-committed:
+			metrics.LockWaitTime.ObserveDuration(time.Since(start))
+			return errLockConflict
+		case <-deadline:
+			metrics.LockWaitTime.ObserveDuration(time.Since(start))
+			return errLockTimeout
+		}
+	}
+}
+
+//wait按key的顺序加锁，遇到冲突一直等到锁释放，stop关闭时放弃并返回false，不按policy放弃，也不计入指标。
+//coordinator用它让写同一个key的轮次一个接一个开始：持有锁的轮次不会再等别的锁，不会死锁
+func (t *lockTable) wait(owner string, keys []string, stop <-chan struct{}) bool {
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	for _, key := range keys {
+		for {
+			t.mu.Lock()
+			l, ok := t.locks[key]
+			if !ok {
+				t.locks[key] = &keyLock{owner: owner, released: make(chan struct{}), committed: make(chan struct{})}
+				t.holders[owner] = append(t.holders[owner], key)
+			}
+			t.mu.Unlock()
+			if !ok || l.owner == owner {
+				break
+			}
+			select {
+			case <-l.released:
+			case <-stop:
+				t.release(owner)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//commit标记owner已经决定提交，锁在它的高度写入之后才释放
+func (t *lockTable) commit(owner string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range t.holders[owner] {
+		if l := t.locks[key]; l != nil && l.owner == owner && !l.closed {
+			l.closed = true
+			close(l.committed)
+		}
+	}
+}
+
+func (t *lockTable) release(owner string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range t.holders[owner] {
+		if l := t.locks[key]; l != nil && l.owner == owner {
+			delete(t.locks, key)
+			close(l.released)
+		}
+	}
+	delete(t.holders, owner)
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/2pc/metrics"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/protocol"
+)
+
+//protocolNode是server里的一个协议节点，Start、Handle和Fire都在mu下调用
+type protocolNode struct {
+	node protocol.Node
+	mu   sync.Mutex
+}
+
+//env是protocol.Env在server里的实现：消息是gRPC调用，定时器是time.AfterFunc。
+//处理收到的请求时，发回给请求方的消息就是这次gRPC调用的回应
+type env struct {
+	s     *Server
+	p     *protocolNode
+	req   *protocol.Message
+	reply *protocol.Message
+	//参与者已经决定提交但是写入失败，回应这个NACK，coordinator重发时再确认
+	nack *pb.Response
+	//由定时器触发时不为空
+	timer *protocol.Timer
+}
+
+func (e *env) Send(m protocol.Message) {
+	if e.req != nil && e.reply == nil && m.To == e.req.From && m.Round == e.req.Round {
+		e.reply = &m
+		return
+	}
+	s := e.s
+	switch m.Kind {
+	case protocol.Propose, protocol.Resolve, protocol.Precommit, protocol.Commit, protocol.Abort:
+		s.asked(m)
+		s.goBackground(func() { s.request(m) })
+	case protocol.Query:
+		s.goBackground(func() { s.query(m) })
+	}
+}
+
+func (e *env) After(d time.Duration, t protocol.Timer) {
+	s, p := e.s, e.p
+	time.AfterFunc(d, func() {
+		s.goBackground(func() { s.fire(p, t) })
+	})
+}
+
+func (e *env) Decided(id protocol.Round, height uint64, o protocol.Outcome) {
+	if e.p == e.s.coordinatorNode {
+		e.s.roundDecided(id, height, o)
+		return
+	}
+	e.s.branchDecided(e, id, height, o)
+}
+
+//response把节点对请求的回复变成gRPC的回应
+func (e *env) response() *pb.Response {
+	if e.nack != nil {
+		return e.nack
+	}
+	if e.reply == nil {
+		//决定没有记下来，coordinator会重发
+		return e.s.nack(pb.Reason_STORAGE_ERROR, "failed to log %s of transaction %s", e.req.Kind, e.req.Round)
+	}
+	switch data := e.reply.Data.(type) {
+	case *pb.Response:
+		return data
+	case error:
+		return e.s.nack(pb.Reason_STORAGE_ERROR, "failed to log prepared height %d: %v", e.reply.Height, data)
+	}
+	switch {
+	case e.reply.Kind == protocol.Vote && !e.reply.Yes:
+		return e.s.nack(pb.Reason_NONE, "transaction %s on height %d has been aborted", e.reply.Round, e.reply.Height)
+	case e.reply.ReadOnly:
+		return &pb.Response{Type: pb.Type_READ_ONLY}
+	}
+	return &pb.Response{Type: pb.Type_ACK}
+}
+
+//handle把收到的请求交给节点处理，返回的env里是给请求方的回复
+func (s *Server) handle(p *protocolNode, m protocol.Message) *env {
+	e := &env{s: s, p: p, req: &m}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.node.Handle(e, m)
+	s.forget(p)
+	return e
+}
+
+//deliver把参与者对coordinator请求的回应交给coordinator
+func (s *Server) deliver(p *protocolNode, m protocol.Message) {
+	e := &env{s: s, p: p}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.node.Handle(e, m)
+	s.forget(p)
+}
+
+func (s *Server) fire(p *protocolNode, t protocol.Timer) {
+	select {
+	case <-s.stopCh:
+		return
+	default:
+	}
+	//停止时取消还没有触发的3PC自动提交，不再碰要关闭的数据库
+	if t.Kind == protocol.AutoCommit && s.isDraining() {
+		return
+	}
+	e := &env{s: s, p: p, timer: &t}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.node.Fire(e, t)
+	s.forget(p)
+}
+
+//forget让参与者忘掉已经写入的高度上有结果的轮次，来晚的旧提案按高度处理
+func (s *Server) forget(p *protocolNode) {
+	if participant, ok := p.node.(*protocol.Participant); ok {
+		participant.Forget(atomic.LoadUint64(&s.Height))
+	}
+}
+
+//request把coordinator的一条消息作为gRPC请求发给参与者，再把回应交回coordinator，
+//没有回应时交回Failed，决定没有确认时coordinator按退避重发
+func (s *Server) request(m protocol.Message) {
+	txid := m.Round.String()
+	cli, err := s.clientFor(m.To)
+	var resp *pb.Response
+	switch {
+	case err != nil:
+	case m.Kind == protocol.Propose || m.Kind == protocol.Resolve:
+		proposal := s.proposal(m)
+		if proposal == nil {
+			return
+		}
+		message := ""
+		if m.OnePhase {
+			message = "one phase"
+		}
+		s.journal.record(pb.JournalEvent_PROPOSED, m.Height, m.To, txid, message)
+		resp, err = call(s.proposeTimeout(), func(ctx context.Context) (*pb.Response, error) {
+			return cli.Propose(ctx, proposal)
+		})
+		s.recordCall(pb.JournalEvent_VOTED, m.Height, m.To, txid, resp, err)
+	case m.Kind == protocol.Precommit:
+		resp, err = call(s.precommitTimeout(), func(ctx context.Context) (*pb.Response, error) {
+			return cli.Precommit(ctx, &pb.PrecommitRequest{Index: m.Height, Txid: txid})
+		})
+		s.recordCall(pb.JournalEvent_PRECOMMITTED, m.Height, m.To, txid, resp, err)
+	default:
+		resp, err = s.sendCommit(cli, &pb.CommitRequest{Index: m.Height, IsRollback: m.Kind == protocol.Abort, Txid: txid})
+	}
+
+	reply := protocol.Message{From: m.To, To: m.From, Round: m.Round, Height: m.Height}
+	switch {
+	case err != nil && (m.Kind == protocol.Commit || m.Kind == protocol.Abort):
+		log.Warn(fmt.Sprintf("%s on height %d not acknowledged by %s, retrying in background: %v", m.Kind, m.Height, m.To, err))
+		return
+	case err != nil:
+		log.Error(err.Error())
+		resp = failedVote(m.To, err)
+		reply.Kind, reply.Request, reply.Data = protocol.Failed, m.Kind, resp
+	case m.Kind == protocol.Propose || m.Kind == protocol.Resolve:
+		reply.Kind, reply.Data = protocol.Vote, resp
+		reply.Yes, reply.ReadOnly = resp.Type != pb.Type_NACK, resp.Type == pb.Type_READ_ONLY
+	case m.Kind == protocol.Precommit && resp.Type == pb.Type_ACK:
+		reply.Kind = protocol.PrecommitAck
+	case m.Kind == protocol.Precommit:
+		reply.Kind, reply.Request, reply.Data = protocol.Failed, m.Kind, resp
+	case resp.Type == pb.Type_ACK:
+		reply.Kind = protocol.Ack
+		if presumed(s.Config.CommitType) {
+			metrics.DecisionAcks.Add(1)
+		}
+	default:
+		//参与者已经决定了，只是写入失败，重发时它会确认
+		log.Warn(fmt.Sprintf("%s on height %d not acknowledged by %s, retrying in background: %v", m.Kind, m.Height, m.To, voteError(m.Kind.String(), resp)))
+		return
+	}
+	s.answered(m, resp)
+	s.deliver(s.coordinatorNode, reply)
+}
+
+//query向coordinator询问不确定的事务的结果，问到之后交给参与者
+func (s *Server) query(m protocol.Message) {
+	cli, err := s.clientFor(m.To)
+	if err != nil {
+		log.Warn(fmt.Sprintf("failed to connect coordinator %s: %v", m.To, err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.commitTimeout())
+	resp, err := cli.Decision(ctx, m.Round.String())
+	cancel()
+	if err != nil || resp.Outcome == pb.Outcome_UNKNOWN {
+		return
+	}
+	kind := protocol.Commit
+	if resp.Outcome == pb.Outcome_ABORTED {
+		kind = protocol.Abort
+	}
+	log.Info(fmt.Sprintf("resolved transaction %s on height %d: %s", m.Round, m.Height, resp.Outcome))
+	s.deliver(s.participantNode, protocol.Message{Kind: kind, From: m.To, To: s.Addr, Round: m.Round, Height: m.Height})
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/client"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+const (
+	//做出决定之后，没有确认的follower由coordinator节点按指数退避一直重发
+	commitRetryBase = 100 * time.Millisecond
+	commitRetryMax  = 5 * time.Second
+)
+
+//各阶段的超时时间，没有单独配置时使用Timeout
+func (s *Server) phaseTimeout(ms uint64) time.Duration {
+	if ms == 0 {
+		ms = s.Config.Timeout
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (s *Server) proposeTimeout() time.Duration {
+	return s.phaseTimeout(s.Config.ProposeTimeout)
+}
+
+func (s *Server) precommitTimeout() time.Duration {
+	return s.phaseTimeout(s.Config.PrecommitTimeout)
+}
+
+func (s *Server) commitTimeout() time.Duration {
+	return s.phaseTimeout(s.Config.CommitTimeout)
+}
+
+//call给一次阶段调用单独设置超时，每个follower的每个阶段都是独立的deadline
+func call(timeout time.Duration, fn func(ctx context.Context) (*pb.Response, error)) (*pb.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+//failedVote把调用follower失败（超时、不可达）也记成一次带原因的拒绝
+func failedVote(addr string, err error) *pb.Response {
+	vote := &pb.Response{Type: pb.Type_NACK, Reason: pb.Reason_UNREACHABLE, Message: err.Error(), Node: addr}
+	if status.Code(err) == codes.DeadlineExceeded {
+		vote.Reason, vote.Message = pb.Reason_TIMEOUT, "timed out"
+	}
+	return vote
+}
+
+//voteError把follower的拒绝汇总成返回给客户端的错误，原始的投票结果放在status的details里，
+//在做出提交决定之前的失败都以回滚结束：超时和不可达可以安全地重试，hook拒绝需要调用方处理
+func voteError(phase string, votes ...*pb.Response) error {
+	code := codes.Aborted
+	reasons := make([]string, 0, len(votes))
+	details := make([]proto.Message, 0, len(votes))
+	for _, vote := range votes {
+		switch vote.Reason {
+		case pb.Reason_HOOK_REJECTED:
+			code = codes.FailedPrecondition
+		case pb.Reason_STORAGE_ERROR, pb.Reason_NO_PROPOSAL:
+			if code != codes.FailedPrecondition {
+				code = codes.Internal
+			}
+		case pb.Reason_BEHIND:
+			if code == codes.Aborted {
+				code = codes.Unavailable
+			}
+		}
+		reasons = append(reasons, fmt.Sprintf("%s %s: %s", vote.Node, strings.ToLower(vote.Reason.String()), vote.Message))
+		details = append(details, vote)
+	}
+	st := status.New(code, fmt.Sprintf("%s rejected, aborted: %s", phase, strings.Join(reasons, "; ")))
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+//rolledBack在错误的details里带上ABORTED，说明这次写入在做出提交决定之前已经回滚，一定没有生效，
+//其他的错误（超时、做出决定之后的失败）都不能确定，客户端用client.RolledBack区分，不需要解析错误信息
+func rolledBack(err error) error {
+	st := status.Convert(err)
+	if withDetails, e := st.WithDetails(&pb.DecisionResponse{Outcome: pb.Outcome_ABORTED}); e == nil {
+		return withDetails.Err()
+	}
+	return err
+}
+
+//precommit失败时已经precommit的3PC follower会在超时后自动提交，回滚没有送到时写入仍然可能生效
+func (s *Server) precommitFailed(err error) error {
+	if s.Config.CommitType != TWO_PHASE {
+		return err
+	}
+	return rolledBack(err)
+}
+
+//sendCommit把提交或者回滚的决定发给一个follower，它的回应记在journal里
+func (s *Server) sendCommit(follower *client.CommitClient, req *pb.CommitRequest) (*pb.Response, error) {
+	resp, err := call(s.commitTimeout(), func(ctx context.Context) (*pb.Response, error) {
+		return follower.Commit(ctx, req)
+	})
+	stage := pb.JournalEvent_COMMITTED
+	if req.IsRollback {
+		stage = pb.JournalEvent_ABORTED
+	}
+	s.recordCall(stage, req.Index, follower.Addr(), req.Txid, resp, err)
+	return resp, err
+}
@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/protocol"
+	"github.com/sysphusking/dsts/2pc/wal"
+)
+
+const walFile = "tpc.wal"
+
+func presumed(commitType string) bool {
+	return commitType == PRESUMED_ABORT || commitType == PRESUMED_COMMIT
+}
+
+func commitTypeOf(commitType string) pb.CommitType {
+	switch commitType {
+	case THREE_PHASE:
+		return pb.CommitType_THREE_PHASE_COMMIT
+	case PRESUMED_ABORT:
+		return pb.CommitType_PRESUMED_ABORT
+	case PRESUMED_COMMIT:
+		return pb.CommitType_PRESUMED_COMMIT
+	}
+	return pb.CommitType_TWO_PHASE_COMMIT
+}
+
+//coordinatorLog是coordinator的protocol.Log：presumed变种的决定写进协议日志，2PC、3PC和一阶段提交的提交记录就是写进数据库的数据，
+//一阶段提交和presumed-commit在发出提案之前还要写collecting记录
+type coordinatorLog struct {
+	s       *Server
+	records []protocol.Record
+}
+
+func (l *coordinatorLog) Records() []protocol.Record {
+	return l.records
+}
+
+func (l *coordinatorLog) Append(r protocol.Record, force bool) error {
+	s := l.s
+	rs := s.roundState(r.Round)
+	rec := wal.Record{
+		TxID:         r.Round.String(),
+		Index:        r.Height,
+		CommitType:   s.Config.CommitType,
+		Participants: r.Participants,
+		OnePhase:     r.OnePhase,
+	}
+	switch r.Type {
+	case protocol.Boot:
+		rec = wal.Record{Type: wal.Boot, Index: r.Round.Epoch}
+	case protocol.Collecting:
+		//一阶段提交重启之后问出提交时，coordinator自己的数据从这里找回
+		rec.Type, rec.Msgs = wal.Collecting, rs.msgs
+		if err := s.wal.Append(rec, force); err != nil {
+			rs.result = &roundResult{err: rolledBack(status.Errorf(codes.Unavailable, "failed to log transaction: %v", err))}
+			return err
+		}
+		return nil
+	case protocol.Decision:
+		if r.OnePhase || !presumed(s.Config.CommitType) {
+			if r.Outcome != protocol.Committed {
+				return nil
+			}
+			//写入之后就是做出了提交的决定，失败时参与者还没有收到提交，改成回滚
+			events, err := s.applyAt(r.Height, rs.msgs)
+			if err != nil {
+				log.Error(fmt.Sprintf("failed to save msg on coordinator: %v", err))
+				rs.result = &roundResult{resp: &pb.Response{Type: pb.Type_NACK}, err: voteError("commit", s.nack(pb.Reason_STORAGE_ERROR, "failed to save msg on coordinator: %v", err))}
+				return err
+			}
+			rs.events = events
+			return nil
+		}
+		rec.Type = wal.Abort
+		if r.Outcome == protocol.Committed {
+			rec.Type, rec.Msgs = wal.Commit, rs.msgs
+		}
+		if err := s.wal.Append(rec, force); err != nil {
+			log.Error(fmt.Sprintf("failed to log %s of %s: %v", rec.Type, rec.TxID, err))
+			if r.Outcome == protocol.Committed {
+				rs.result = &roundResult{resp: &pb.Response{Type: pb.Type_NACK}, err: rolledBack(voteError("commit", s.nack(pb.Reason_STORAGE_ERROR, "failed to log commit: %v", err)))}
+			}
+			return err
+		}
+		return nil
+	case protocol.End:
+		if !r.OnePhase && !presumed(s.Config.CommitType) {
+			return nil
+		}
+		rec = wal.Record{Type: wal.End, TxID: rec.TxID, Index: r.Height}
+	}
+	return s.wal.Append(rec, force)
+}
+
+//participantLog是参与者的protocol.Log：presumed变种投票之前把要提交的数据写进prepared记录，之后的决定也写进协议日志；
+//presumed-commit还要记住回滚，coordinator忘掉这一轮之后会按提交回答。其余的决定就是写进数据库的数据
+type participantLog struct {
+	s       *Server
+	records []protocol.Record
+}
+
+func (l *participantLog) Records() []protocol.Record {
+	return l.records
+}
+
+func (l *participantLog) Append(r protocol.Record, force bool) error {
+	s := l.s
+	b := s.branches[r.Round]
+	rec := wal.Record{TxID: r.Round.String(), Index: r.Height, CommitType: s.Config.CommitType, Participant: true}
+	switch r.Type {
+	case protocol.Prepared:
+		rec.Type, rec.Coordinator = wal.Prepared, r.Coordinator
+		if b != nil {
+			rec.Msgs = b.msgs
+		}
+		if err := s.wal.Append(rec, force); err != nil {
+			log.Error(fmt.Sprintf("failed to log prepared height %d: %v", r.Height, err))
+			return err
+		}
+		if b != nil {
+			b.logged = true
+		}
+	case protocol.Decision:
+		//一阶段提交的决定就是写进数据库的数据，写入失败时改成回滚
+		if b != nil && b.onePhase && r.Outcome == protocol.Committed {
+			b.err = s.commitOnePhase(rec.TxID, b)
+			return b.err
+		}
+		if (b == nil || !b.logged) && !(r.Outcome == protocol.Aborted && s.Config.CommitType == PRESUMED_COMMIT) {
+			return nil
+		}
+		rec.Type = wal.Commit
+		if r.Outcome == protocol.Aborted {
+			rec.Type = wal.Abort
+		}
+		return s.wal.Append(rec, force)
+	}
+	return nil
+}
+
+//openWal打开协议日志，把留下的记录交给coordinator和参与者恢复，只留下仍然需要的记录，
+//然后启动两个协议节点：没有结束的决定继续发送，不确定的事务继续询问
+func (s *Server) openWal() error {
+	l, records, err := wal.Open(filepath.Join(s.Config.WalDir, walFile))
+	if err != nil {
+		return err
+	}
+	s.wal = l
+	s.rounds = map[protocol.Round]*roundState{}
+	s.branches = map[protocol.Round]*branch{}
+	clog, plog := &coordinatorLog{s: s}, &participantLog{s: s}
+
+	var (
+		order   []string
+		txs     = map[string][]wal.Record{}
+		pending []wal.Record
+		boot    *wal.Record
+	)
+	for i, rec := range records {
+		if rec.Type == wal.Boot {
+			if boot == nil || rec.Index > boot.Index {
+				boot = &records[i]
+			}
+			continue
+		}
+		if _, ok := txs[rec.TxID]; !ok {
+			order = append(order, rec.TxID)
+		}
+		txs[rec.TxID] = append(txs[rec.TxID], rec)
+	}
+	if boot != nil {
+		clog.records = append(clog.records, protocol.Record{Type: protocol.Boot, Round: protocol.Round{Epoch: boot.Index}})
+		pending = append(pending, *boot)
+	}
+	for _, txid := range order {
+		recs := txs[txid]
+		id, err := protocol.ParseRound(txid)
+		if err != nil {
+			log.Warn(fmt.Sprintf("ignoring transaction %s logged by an older version", txid))
+			continue
+		}
+		keep := false
+		if participantRecords(recs) {
+			keep = s.recoverBranch(id, recs, plog)
+		} else {
+			keep = s.recoverRound(id, recs, clog)
+		}
+		if keep {
+			pending = append(pending, recs...)
+		}
+	}
+	if err := l.Rewrite(pending); err != nil {
+		return err
+	}
+	//新的轮次从还没有用过的高度开始，重启之前没有提交的末尾的高度可以复用
+	s.next = atomic.LoadUint64(&s.Height)
+	for _, rs := range s.rounds {
+		if rs.index >= s.next {
+			s.next = rs.index + 1
+		}
+	}
+
+	mode, err := protocol.ParseMode(s.Config.CommitType)
+	if err != nil {
+		mode = protocol.ThreePhase
+	}
+	inquire := 2 * s.commitTimeout()
+	if inquire < time.Second {
+		inquire = time.Second
+	}
+	timeouts := protocol.Timeouts{
+		Vote:       s.proposeTimeout(),
+		Precommit:  s.precommitTimeout(),
+		AutoCommit: time.Duration(s.Config.Timeout) * time.Millisecond,
+		Inquire:    inquire,
+		Resend:     commitRetryBase,
+		MaxResend:  commitRetryMax,
+	}
+	s.coordinatorNode = &protocolNode{node: &protocol.Coordinator{ID: s.Addr, Mode: mode, Timeouts: timeouts, Log: clog}}
+	s.participantNode = &protocolNode{node: &protocol.Participant{
+		ID:        s.Addr,
+		Mode:      mode,
+		Timeouts:  timeouts,
+		Vote:      s.vote,
+		Committed: s.committed,
+		Log:       plog,
+	}}
+	for _, p := range []*protocolNode{s.coordinatorNode, s.participantNode} {
+		p.mu.Lock()
+		err := p.node.Start(&env{s: s, p: p})
+		p.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//participantRecords判断一个事务的记录是不是参与者写的，旧的日志里参与者的决定没有标记，跟在prepared记录后面
+func participantRecords(recs []wal.Record) bool {
+	for _, rec := range recs {
+		if rec.Participant || rec.Type == wal.Prepared {
+			return true
+		}
+	}
+	return false
+}
+
+func outcomeOf(t wal.RecordType) protocol.Outcome {
+	if t == wal.Commit {
+		return protocol.Committed
+	}
+	return protocol.Aborted
+}
+
+//recoverBranch把参与者的记录交给参与者节点，返回是否还要留在日志里：
+//没有决定的要等coordinator，已经决定但还没有写入的高度重启之后再写一次
+func (s *Server) recoverBranch(id protocol.Round, recs []wal.Record, l *participantLog) bool {
+	var prepared, decision *wal.Record
+	for i := range recs {
+		if recs[i].Type == wal.Prepared {
+			prepared = &recs[i]
+		} else {
+			decision = &recs[i]
+		}
+	}
+	if prepared != nil {
+		s.branches[id] = &branch{index: prepared.Index, msgs: prepared.Msgs, logged: true}
+		//还在等决定的分支重新锁住它要写的key
+		if decision == nil {
+			s.locks.acquire(prepared.TxID, 0, msgKeys(prepared.Msgs))
+		}
+		l.records = append(l.records, protocol.Record{Type: protocol.Prepared, Round: id, Height: prepared.Index, Coordinator: prepared.Coordinator})
+	}
+	if decision == nil {
+		log.Info(fmt.Sprintf("transaction %s on height %d is in doubt", prepared.TxID, prepared.Index))
+		return true
+	}
+	l.records = append(l.records, protocol.Record{Type: protocol.Decision, Round: id, Height: decision.Index, Outcome: outcomeOf(decision.Type)})
+	return decision.Index >= atomic.LoadUint64(&s.Height)
+}
+
+//recoverRound把coordinator的记录交给coordinator节点，返回是否还要留在日志里。
+//一阶段提交没有决定的记录，coordinator在这个高度上写入过数据说明已经提交，否则要向参与者问出结果
+func (s *Server) recoverRound(id protocol.Round, recs []wal.Record, l *coordinatorLog) bool {
+	var collecting, decision *wal.Record
+	for i := range recs {
+		switch recs[i].Type {
+		case wal.End:
+			return false
+		case wal.Collecting:
+			collecting = &recs[i]
+		default:
+			decision = &recs[i]
+		}
+	}
+	rs := newRoundState(0, nil)
+	rs.recovered = true
+	if collecting != nil {
+		if collecting.OnePhase && decision == nil && len(collecting.Msgs) > 0 && s.writtenAt(collecting.Index) {
+			return false
+		}
+		rs.index, rs.msgs, rs.onePhase = collecting.Index, collecting.Msgs, collecting.OnePhase
+		l.records = append(l.records, protocol.Record{
+			Type:         protocol.Collecting,
+			Round:        id,
+			Height:       collecting.Index,
+			Participants: collecting.Participants,
+			OnePhase:     collecting.OnePhase,
+		})
+	}
+	if decision != nil {
+		rs.index, rs.msgs = decision.Index, decision.Msgs
+		l.records = append(l.records, protocol.Record{
+			Type:         protocol.Decision,
+			Round:        id,
+			Height:       decision.Index,
+			Outcome:      outcomeOf(decision.Type),
+			Participants: decision.Participants,
+			OnePhase:     decision.OnePhase,
+		})
+	}
+	s.rounds[id] = rs
+	return true
+}
+
+//Decision回答参与者对某个事务结果的询问，coordinator已经忘掉的事务按照推定规则回答
+func (s *Server) Decision(ctx context.Context, request *pb.DecisionRequest) (*pb.DecisionResponse, error) {
+	if !presumed(s.Config.CommitType) {
+		return &pb.DecisionResponse{Outcome: pb.Outcome_UNKNOWN}, nil
+	}
+	round, err := protocol.ParseRound(request.Txid)
+	if err != nil {
+		return &pb.DecisionResponse{Outcome: pb.Outcome_UNKNOWN}, nil
+	}
+	e := s.handle(s.coordinatorNode, protocol.Message{Kind: protocol.Query, From: peerOf(ctx), To: s.Addr, Round: round})
+	switch {
+	case e.reply == nil:
+		//还在收集投票
+		return &pb.DecisionResponse{Outcome: pb.Outcome_UNKNOWN}, nil
+	case e.reply.Kind == protocol.Commit:
+		return &pb.DecisionResponse{Outcome: pb.Outcome_COMMITTED}, nil
+	}
+	return &pb.DecisionResponse{Outcome: pb.Outcome_ABORTED}, nil
+}
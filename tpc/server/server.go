@@ -3,55 +3,118 @@ package server
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
-	"github.com/sysphusking/dsts/2pc/cache"
 	"github.com/sysphusking/dsts/2pc/client"
 	"github.com/sysphusking/dsts/2pc/config"
 	"github.com/sysphusking/dsts/2pc/db"
+	"github.com/sysphusking/dsts/2pc/metrics"
 	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/protocol"
+	"github.com/sysphusking/dsts/2pc/shard"
+	"github.com/sysphusking/dsts/2pc/wal"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 )
 
 const (
-	TWO_PHASE   = "two-phase"
-	THREE_PHASE = "three-phase"
+	TWO_PHASE       = "two-phase"
+	THREE_PHASE     = "three-phase"
+	PRESUMED_ABORT  = "presumed-abort"
+	PRESUMED_COMMIT = "presumed-commit"
 )
 
 type Option func(server *Server) error
 
-type Server struct {
-	Addr                 string
-	Followers            []*client.CommitClient
-	Config               *config.Config
-	GrpcServer           *grpc.Server
-	DB                   db.Database
-	ProposeHook          func(req *pb.ProposeRequest) bool
-	CommitHook           func(req *pb.CommitRequest) bool
-	NodeCache            cache.ICache
-	Height               uint64
-	cancelCommitOnHeight map[uint64]bool
-	mu                   sync.RWMutex
-}
-
-func (s *Server) SetCancelCache(height uint64, doCancel bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.cancelCommitOnHeight[height] = doCancel
+//WithDB使用给定的存储，不再连接配置里的MySQL，比如在进程内用db.NewMemory跑节点
+func WithDB(database db.Database) Option {
+	return func(server *Server) error {
+		server.DB = database
+		return nil
+	}
 }
 
-func (s *Server) GetCancelCache(height uint64) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.cancelCommitOnHeight[height]
+type Server struct {
+	Addr        string
+	Followers   []*client.CommitClient
+	Config      *config.Config
+	GrpcServer  *grpc.Server
+	httpServer  *http.Server
+	DB          db.Database
+	ProposeHook func(req *pb.ProposeRequest) bool
+	CommitHook  func(req *pb.CommitRequest) bool
+	Height      uint64
+	watchHub    *watchHub
+	dedup       *dedupTable
+	batcher     *batcher
+	//这个高度之前的提交已经被压缩，只能通过快照获取
+	compacted uint64
+	//follower追数据时连接的coordinator
+	coordinator *client.CommitClient
+	catchingUp  int32
+	//Height之后已经有了结果、还在等前面高度的高度，按高度的顺序推进
+	settled   map[uint64]*settlement
+	settledMu sync.Mutex
+	//参与者上的key锁
+	locks *lockTable
+	//coordinator上进行中的轮次要写的key，写同一个key的轮次一个接一个开始，coordinator按高度的顺序写入它们
+	writing *lockTable
+	//协议日志：presumed变种的决定、参与者的prepared记录和一阶段提交的collecting记录
+	wal *wal.Log
+	//coordinator和参与者的协议部分，和sim包检查的是同一份代码
+	coordinatorNode *protocolNode
+	participantNode *protocolNode
+	//coordinator分配给下一轮的高度，只在coordinatorNode.mu下访问
+	next uint64
+	//coordinator上还没有做出决定的轮次
+	rounds   map[protocol.Round]*roundState
+	roundsMu sync.Mutex
+	//参与者投了同意票、还在等决定的轮次，只在participantNode.mu下访问
+	branches map[protocol.Round]*branch
+	//参与者使用XA后端时不为空
+	xa *db.XA
+	//按key分片时每个分片的follower，coordinator用它们路由写入和读取
+	shards         *shard.Map
+	shardFollowers [][]*client.CommitClient
+	//收到过分片的提案，说明这个follower只保存部分key
+	sharded int32
+	//到各个参与者的连接，包括Followers、分片里的follower和事务登记的参与者
+	clients   map[string]*client.CommitClient
+	clientsMu sync.Mutex
+	txs       *txTable
+	//协议事件，通过Journal接口按高度查询
+	journal *journal
+	//Stop开始时关闭drainCh并设置draining，之后不再接受新的Put和提案
+	draining int32
+	drainCh  chan struct{}
+	//后台发送决定、重试commit、3PC自动提交、追数据和快照的协程，Stop在关闭数据库之前等待它们，
+	//等到最后一批之后bgClosed，不再启动新的
+	bg       sync.WaitGroup
+	bgMu     sync.Mutex
+	bgClosed bool
+	stopCh   chan struct{}
+	mu       sync.RWMutex
 }
 
-func (s *Server) rollback() {
-	s.NodeCache.Delete(s.Height)
+//clientFor返回到addr的连接，没有时新建一个
+func (s *Server) clientFor(addr string) (*client.CommitClient, error) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if cli, ok := s.clients[addr]; ok {
+		return cli, nil
+	}
+	cli, err := client.New(addr)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[addr] = cli
+	return cli, nil
 }
 
 func NewCommitServer(conf *config.Config, opts ...Option) (*Server, error) {
@@ -72,8 +135,9 @@ func NewCommitServer(conf *config.Config, opts ...Option) (*Server, error) {
 		}
 	}
 
+	server.clients = map[string]*client.CommitClient{}
 	for _, node := range conf.Followers {
-		cli, err := client.New(node)
+		cli, err := server.clientFor(node)
 		if err != nil {
 			return nil, err
 		}
@@ -85,15 +149,54 @@ func NewCommitServer(conf *config.Config, opts ...Option) (*Server, error) {
 		server.Config.Coordinator = server.Addr
 	}
 
-	server.DB, err = db.New(viper.GetString("db.address"),
-		viper.GetString("db.username"), viper.GetString("db.password"))
+	if conf.Role == "coordinator" && len(conf.Shards) > 0 {
+		if err = server.initShards(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Role != "coordinator" && conf.Coordinator != "" {
+		if server.coordinator, err = client.New(conf.Coordinator); err != nil {
+			return nil, err
+		}
+	}
+
+	if server.DB == nil {
+		server.DB, err = db.New(viper.GetString("db.address"),
+			viper.GetString("db.username"), viper.GetString("db.password"))
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	server.NodeCache = cache.New()
-	server.cancelCommitOnHeight = map[uint64]bool{}
+	server.watchHub = newWatchHub()
+	server.dedup = newDedupTable(conf.DedupSize)
+	server.txs = newTxTable()
+	server.journal = newJournal(server.Addr)
+	server.settled = map[uint64]*settlement{}
+	server.locks = newLockTable(conf.LockPolicy, server.phaseTimeout(conf.LockTimeout))
+	server.writing = newLockTable(WAIT_DIE, 0)
+	server.stopCh = make(chan struct{})
+	server.drainCh = make(chan struct{})
+	server.batcher = newBatcher(conf.BatchSize, time.Duration(conf.BatchLinger)*time.Millisecond, server.round, server.stopCh)
+	if err = server.loadHeight(); err != nil {
+		return nil, err
+	}
+	if conf.Backend == BACKEND_XA {
+		if server.xa, err = db.NewXA(server.DB, server.Addr); err != nil {
+			return nil, err
+		}
+	}
+	if err = server.openWal(); err != nil {
+		return nil, err
+	}
 
-	if server.Config.CommitType == TWO_PHASE {
+	switch server.Config.CommitType {
+	case TWO_PHASE:
 		log.Info("two phase commit enabled")
-	} else {
+	case PRESUMED_ABORT, PRESUMED_COMMIT:
+		log.Info(fmt.Sprintf("%s commit enabled", server.Config.CommitType))
+	default:
 		log.Info("three phase commit enabled")
 	}
 
@@ -102,7 +205,18 @@ func NewCommitServer(conf *config.Config, opts ...Option) (*Server, error) {
 
 func (s *Server) Stop() {
 	log.Info("Stopping server")
-	s.GrpcServer.GracefulStop()
+	s.drain(time.Now().Add(time.Duration(s.Config.ShutdownTimeout) * time.Millisecond))
+	s.clientsMu.Lock()
+	for _, cli := range s.clients {
+		cli.Close()
+	}
+	s.clientsMu.Unlock()
+	if s.coordinator != nil {
+		s.coordinator.Close()
+	}
+	if err := s.wal.Close(); err != nil {
+		log.Info("failed to close wal ,err : ", zap.Error(err))
+	}
 	if err := s.DB.Close(); err != nil {
 		log.Info("failed to close db ,err : ", zap.Error(err))
 	}
@@ -111,7 +225,12 @@ func (s *Server) Stop() {
 
 func (s *Server) Run(opts ...grpc.UnaryServerInterceptor) {
 	var err error
-	s.GrpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(opts...))
+	s.GrpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(opts...),
+		//允许客户端在没有请求时也发送keepalive ping
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             5 * time.Second,
+			PermitWithoutStream: true,
+		}))
 	pb.RegisterCommitServer(s.GrpcServer, s)
 	l, err := net.Listen("tcp", s.Addr)
 
@@ -121,4 +240,25 @@ func (s *Server) Run(opts ...grpc.UnaryServerInterceptor) {
 
 	log.Info(fmt.Sprintf("listening on tcp://%s", s.Addr))
 	go s.GrpcServer.Serve(l)
+
+	go s.batcher.run()
+	if s.Config.SnapshotInterval > 0 {
+		s.goBackground(s.runSnapshots)
+	}
+	if s.Config.HttpAddr != "" {
+		s.httpServer = &http.Server{Addr: s.Config.HttpAddr, Handler: s.gateway()}
+		go func() {
+			log.Info(fmt.Sprintf("http gateway listening on %s", s.Config.HttpAddr))
+			if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("failed to serve http gateway, err: ", err)
+			}
+		}()
+	}
+	if s.Config.MetricsAddr != "" {
+		go func() {
+			if err := metrics.Serve(s.Config.MetricsAddr); err != nil {
+				log.Error("failed to serve metrics, err: ", err)
+			}
+		}()
+	}
 }
@@ -2,187 +2,699 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"sort"
 	"sync/atomic"
 	"time"
 
-	"google.golang.org/grpc/metadata"
-
 	log "github.com/sirupsen/logrus"
 
 	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/client"
+	"github.com/sysphusking/dsts/2pc/metrics"
 	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/protocol"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-func (s *Server) Propose(ctx context.Context, request *pb.ProposeRequest) (*pb.Response, error) {
-	s.SetCancelCache(request.Index, false)
-	return ProposeHandler(ctx, request, s.ProposeHook, s.NodeCache)
+//branch是参与者投了同意票、还在等决定的一轮，提交时写入msgs，只在participantNode.mu下访问
+type branch struct {
+	index    uint64
+	msgs     []cache.Msg
+	onePhase bool
+	//写过prepared记录，决定也要写进协议日志
+	logged bool
+	//一阶段提交写入失败的原因
+	err error
+	//一阶段提交写入之后通知watcher的事件
+	events []*pb.WatchEvent
 }
 
-func (s *Server) Precommit(ctx context.Context, request *pb.PrecommitRequest) (*pb.Response, error) {
+//proposed是交给参与者节点的提案，locked是投票之前给要写的key加锁的结果
+type proposed struct {
+	request *pb.ProposeRequest
+	locked  error
+}
 
-	if s.Config.CommitType == THREE_PHASE {
-		//设置超时
-		ctx, _ = context.WithTimeout(context.Background(), time.Duration(s.Config.Timeout)*time.Millisecond)
-		//总体来说这个机制不是很好，超时了没法用，比较鸡肋
-		go func(ctx context.Context) {
-		ForLoop:
-			for {
-				select {
-				//超时后自动执行
-				case <-ctx.Done():
-					md := metadata.Pairs("mode", "autocommit")
-					ctx := metadata.NewOutgoingContext(context.Background(), md)
-					//这里的超时机制不会执行到CommitHandler里，不知道存在意义是什么
-					if !s.GetCancelCache(request.Index) {
-						s.Commit(ctx, &pb.CommitRequest{Index: s.Height})
-						log.Info("commit without coordinator after timeout ")
-					}
-					break ForLoop
-				}
-			}
-		}(ctx)
+func (s *Server) Propose(ctx context.Context, request *pb.ProposeRequest) (resp *pb.Response, err error) {
+	from := peerOf(ctx)
+	s.journal.record(pb.JournalEvent_PROPOSED, request.Index, from, request.Txid, "")
+	defer func() {
+		s.sign(resp)
+		s.recordCall(pb.JournalEvent_VOTED, request.Index, from, request.Txid, resp, err)
+		//一阶段提交在投票的同时已经提交
+		if request.OnePhase && err == nil && resp.Type == pb.Type_ACK {
+			s.journal.record(pb.JournalEvent_COMMITTED, request.Index, from, request.Txid, "one phase")
+		}
+	}()
+	round, err := protocol.ParseRound(request.Txid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	kind := protocol.Propose
+	if request.Resolve {
+		kind = protocol.Resolve
+	}
+	//presumed变种的参与者不确定时向发起这一轮的coordinator询问
+	coordinator := request.Coordinator
+	if coordinator == "" {
+		coordinator = s.Config.Coordinator
+	}
+	//在交给参与者节点之前加锁，等锁的时候其他轮次照常投票、提交和回滚；重复的提案按之前的票回答，不再加锁
+	var locked error
+	if !request.Resolve && !s.voted(round) {
+		locked = s.locks.acquire(request.Txid, request.Timestamp, msgKeys(proposedMsgs(request)))
+	}
+	e := s.handle(s.participantNode, protocol.Message{
+		Kind:     kind,
+		From:     coordinator,
+		To:       s.Addr,
+		Round:    round,
+		Height:   request.Index,
+		OnePhase: request.OnePhase,
+		Data:     &proposed{request: request, locked: locked},
+	})
+	return e.response(), nil
+}
+
+func (s *Server) voted(id protocol.Round) bool {
+	p := s.participantNode
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.node.(*protocol.Participant).Voted(id)
+}
+
+//vote是参与者节点的投票：高度已经越过、正在停止、key被锁住或者hook拒绝时反对，只读的参与者不参加后面的阶段，
+//XA后端在投票之前PREPARE，一阶段提交同时检查commit hook
+func (s *Server) vote(m protocol.Message) protocol.Ballot {
+	prop := m.Data.(*proposed)
+	request, txid := prop.request, m.Round.String()
+	//分片的follower只收到写到自己的高度，高度有空洞，不按高度的顺序写入
+	if request.Sharded {
+		atomic.StoreInt32(&s.sharded, 1)
+	}
+	sequenced := !s.isSharded()
+	if sequenced {
+		//这个高度已经有了结果，只有重发的一阶段提交可能已经在这里提交过了
+		if height := atomic.LoadUint64(&s.Height); request.Index < height && !(request.OnePhase && s.writtenAt(request.Index)) {
+			return s.reject(pb.Reason_NONE, "height %d has been settled, now at %d", request.Index, height)
+		}
+		//coordinator在回滚留下的空洞上开始了新的一轮
+		if s.unsettle(request.Index) {
+			return s.reject(pb.Reason_NONE, "height %d has been committed", request.Index)
+		}
+		//coordinator上更早的轮次都已经有了结果，本地还有不知道结果的高度时在后台追数据，不影响这一轮投票
+		if s.unknownBefore(request.Resolved) {
+			s.catchUp(request.Resolved)
+		}
+	}
+	//正在停止的节点不再接受新的事务，已经投过票的事务仍然可以提交或者回滚
+	if s.isDraining() {
+		return s.reject(pb.Reason_UNREACHABLE, "node is shutting down")
 	}
+	if prop.locked != nil {
+		return s.reject(pb.Reason_LOCK_CONFLICT, "%v", prop.locked)
+	}
+	if !s.ProposeHook(request) {
+		return s.reject(pb.Reason_HOOK_REJECTED, "rejected by propose hook")
+	}
+	msgs := proposedMsgs(request)
+	for _, msg := range msgs {
+		log.Info(fmt.Sprintf("Propose Received: %s=%s\n", msg.Key, string(msg.Value)))
+	}
+	switch {
+	case request.OnePhase:
+		if !s.CommitHook(&pb.CommitRequest{Index: request.Index, Txid: txid}) {
+			return s.reject(pb.Reason_HOOK_REJECTED, "rejected by commit hook")
+		}
+	//前面的高度还没有写入时本地的值不一定是这一轮之前的值
+	case (!sequenced || s.settledBefore(request.Index)) && readOnly(s.DB, msgs):
+		//只读的参与者不参加后面的阶段，这个高度在本地是空洞
+		s.locks.release(txid)
+		if sequenced {
+			s.settle(request.Index, &settlement{})
+		}
+		return protocol.Ballot{Yes: true, ReadOnly: true}
+	case s.xa != nil:
+		//XA后端在投票之前PREPARE，投了ACK之后commit不会再因为数据库失败
+		if err := s.xaPrepare(txid, request.Index, msgs); err != nil {
+			return s.reject(pb.Reason_STORAGE_ERROR, "%v", err)
+		}
+	}
+	s.branches[m.Round] = &branch{index: request.Index, msgs: msgs, onePhase: request.OnePhase}
+	return protocol.Ballot{Yes: true}
+}
+
+func (s *Server) reject(reason pb.Reason, format string, args ...interface{}) protocol.Ballot {
+	return protocol.Ballot{Data: s.nack(reason, format, args...)}
+}
+
+//committed回答重启之后的coordinator对一阶段提交的询问：参与者已经忘掉了这一轮，这个高度上有写入说明已经提交，
+//coordinator问出结果之前不会在这个高度上开始别的轮次
+func (s *Server) committed(m protocol.Message) bool {
+	return s.writtenAt(m.Height)
+}
 
+//nack生成带原因的拒绝
+func (s *Server) nack(reason pb.Reason, format string, args ...interface{}) *pb.Response {
+	return &pb.Response{Type: pb.Type_NACK, Reason: reason, Message: fmt.Sprintf(format, args...), Node: s.Addr}
+}
+
+//sign在回应里带上本节点的地址，coordinator汇总错误时可以知道是谁拒绝的
+func (s *Server) sign(resp *pb.Response) {
+	if resp != nil {
+		resp.Node = s.Addr
+	}
+}
+
+func (s *Server) Precommit(ctx context.Context, request *pb.PrecommitRequest) (*pb.Response, error) {
+	s.journal.record(pb.JournalEvent_PRECOMMITTED, request.Index, peerOf(ctx), request.Txid, "")
+	round, err := protocol.ParseRound(request.Txid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	//3PC的参与者precommit之后超时会自动提交，coordinator决定回滚而回滚没有送到时，自动提交会破坏原子性，
+	//sim包在同一份协议代码上可以复现这种调度
+	s.handle(s.participantNode, protocol.Message{Kind: protocol.Precommit, From: peerOf(ctx), To: s.Addr, Round: round, Height: request.Index})
 	return PreCommitHandler(ctx, request)
 }
 
 func (s *Server) Commit(ctx context.Context, request *pb.CommitRequest) (resp *pb.Response, err error) {
-
-	if s.Config.CommitType == THREE_PHASE {
-		md, ok := metadata.FromIncomingContext(ctx)
-		//log.Info(fmt.Sprintf("commit md: %v, %v", md, ok))
-		if !ok {
-			return nil, status.Errorf(codes.Internal, "no metadata")
-		}
-		meta := md["mode"]
-		//如果没有设置autocommit，则是正常提交
-		if len(meta) == 0 {
-			//设置成true是不让preCommit中的协程进行重复的commit调用
-			fmt.Println("bbbb")
-			s.SetCancelCache(s.Height, true)
-			resp, err = CommitHandler(ctx, request, s.CommitHook, s.DB, s.NodeCache)
-			if err != nil {
-				return nil, err
-			}
-			if resp.Type == pb.Type_ACK {
-				atomic.AddUint64(&s.Height, 1)
-			}
-			//这里不写是在返回里有声明了
-			return
+	defer func() {
+		s.sign(resp)
+		stage := pb.JournalEvent_COMMITTED
+		if request.IsRollback {
+			stage = pb.JournalEvent_ABORTED
 		}
+		s.recordCall(stage, request.Index, peerOf(ctx), request.Txid, resp, err)
+	}()
+	round, err := protocol.ParseRound(request.Txid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	kind := protocol.Commit
+	if request.IsRollback {
+		kind = protocol.Abort
+	}
+	e := s.handle(s.participantNode, protocol.Message{Kind: kind, From: peerOf(ctx), To: s.Addr, Round: round, Height: request.Index})
+	return e.response(), nil
+}
 
-		//本次请求是否回滚
-		if request.IsRollback {
-			s.rollback()
+//branchDecided按参与者节点的决定写入或者丢掉这一轮的数据，写入失败时回应NACK，决定不会再改变。
+//回滚时立即释放key锁，提交的数据按高度的顺序写入，写入之后释放
+func (s *Server) branchDecided(e *env, id protocol.Round, index uint64, o protocol.Outcome) {
+	txid := id.String()
+	b := s.branches[id]
+	delete(s.branches, id)
+	release := func() { s.locks.release(txid) }
+	if o == protocol.Aborted {
+		release()
+		if b != nil && b.err != nil {
+			e.nack = s.nack(pb.Reason_STORAGE_ERROR, "%v", b.err)
 		}
-	} else {
-		resp, err = CommitHandler(ctx, request, s.CommitHook, s.DB, s.NodeCache)
-		if err != nil {
-			return
+		if s.xa != nil && s.xa.Prepared(txid) {
+			if err := s.rollbackBranch(txid); err != nil {
+				log.Warn(fmt.Sprintf("failed to rollback transaction %s: %v", txid, err))
+			}
 		}
-		if resp.Type == pb.Type_ACK {
-			atomic.AddUint64(&s.Height, 1)
+		//没有写入的高度在本地是空洞
+		s.settleAt(index, &settlement{})
+		return
+	}
+	s.locks.commit(txid)
+	//一阶段提交在记下决定时已经写入
+	if b != nil && b.onePhase {
+		s.settleAt(index, &settlement{committed: true, apply: eventsOf(b.events), done: release})
+		return
+	}
+	if e.timer != nil && e.timer.Kind == protocol.AutoCommit {
+		s.journal.record(pb.JournalEvent_TIMEOUT_FIRED, index, "", txid, "autocommit without coordinator")
+		log.Info(fmt.Sprintf("commit height %d without coordinator after timeout", index))
+	}
+	if b == nil {
+		if s.xa == nil || !s.xa.Prepared(txid) {
+			release()
+			//这个高度已经提交过了（比如追数据时已经写入），否则没有数据可以提交，通过追数据补上；
+			//分片的follower上高度有空洞，不能按高度判断
+			if index >= atomic.LoadUint64(&s.Height) || s.isSharded() {
+				e.nack = s.nack(pb.Reason_NO_PROPOSAL, "no proposal for transaction %s on height %d", txid, index)
+			}
+			return
 		}
+		//重启之后从XA RECOVER找回的分支没有数据
+		b = &branch{index: index}
+	}
+	if !s.CommitHook(&pb.CommitRequest{Index: index, Txid: txid}) {
+		release()
+		s.rollbackBranch(txid)
+		e.nack = s.nack(pb.Reason_HOOK_REJECTED, "rejected by commit hook")
 		return
 	}
-	return
+	log.Info(fmt.Sprintf("Committing on height: %d\n", index))
+	msgs := b.msgs
+	err := s.settleAt(index, &settlement{
+		committed: true,
+		apply:     func() ([]*pb.WatchEvent, error) { return s.commitBranch(txid, index, msgs) },
+		done:      release,
+	})
+	if err != nil {
+		e.nack = s.nack(pb.Reason_STORAGE_ERROR, "%v", err)
+	}
 }
 
 func (s *Server) Put(ctx context.Context, entry *pb.Entry) (*pb.Response, error) {
+	if s.isDraining() {
+		return nil, errDraining
+	}
+	//并发的Put会被合并到同一轮里提交
+	if entry.RequestId == "" {
+		r := s.batcher.submit(ctx, entry, nil)
+		return r.resp, r.err
+	}
+	call, owner := s.dedup.begin(entry.RequestId)
+	if !owner {
+		//同一个id的请求已经执行过或者正在执行，直接返回它的结果
+		return s.dedup.wait(ctx, call)
+	}
+	//调用方超时放弃之后这一轮仍然可能提交，记录的是这一轮真正的结果，
+	//只有确定回滚、没有写入任何数据的请求才忘掉，重试时重新发起一轮
+	r := s.batcher.submit(ctx, entry, func(r roundResult) {
+		s.dedup.finish(entry.RequestId, call, r.resp, r.err, r.decided || !client.RolledBack(r.err))
+	})
+	return r.resp, r.err
+}
 
-	var (
-		response *pb.Response
-		err      error
-	)
-	var ctype pb.CommitType
-	if s.Config.CommitType == THREE_PHASE {
-		ctype = pb.CommitType_THREE_PHASE_COMMIT
-	} else {
-		ctype = pb.CommitType_TWO_PHASE_COMMIT
+//round把batcher合并的Put按分片发给follower，跑一轮propose/precommit/commit
+func (s *Server) round(msgs []cache.Msg) roundResult {
+	targets, err := s.route(msgs)
+	if err != nil {
+		return roundResult{err: rolledBack(err)}
 	}
+	return s.runRound(targets, msgs, s.shards != nil)
+}
 
-	//propose
-	s.NodeCache.Set(s.Height, entry.Key, entry.Value)
-	for _, follower := range s.Followers {
-		if s.Config.CommitType == THREE_PHASE {
-			ctx, _ = context.WithTimeout(ctx, time.Duration(s.Config.Timeout)*time.Millisecond)
-		}
-		response, err = follower.Propose(ctx, &pb.ProposeRequest{
-			Key:        entry.Key,
-			Value:      entry.Value,
-			CommitType: ctype,
-			Index:      s.Height,
-		})
-		if err != nil {
-			log.Error(err.Error())
-			return &pb.Response{Type: pb.Type_NACK}, nil
-		}
-		if response.Type != pb.Type_ACK {
-			return nil, status.Error(codes.Internal, "follower not acknowledged msg")
-		}
+//roundState是coordinator上一轮在协议之外的状态：coordinator自己要写入的数据、发给每个参与者的提案、
+//回滚时汇总的原因和等待结果的调用方
+type roundState struct {
+	index     uint64
+	msgs      []cache.Msg
+	partial   bool
+	onePhase  bool
+	proposals map[string][]cache.Msg
+	//当前阶段把请求发给了谁、收到了谁的回应，进入precommit时重新开始
+	precommit bool
+	asked     map[string]bool
+	answered  map[string]bool
+	failures  []*pb.Response
+	//做出决定时已经确定的结果，比如写协议日志或者coordinator自己的数据失败
+	result *roundResult
+	done   chan roundResult
+	//开始的时间，参与者按它判断事务的新老
+	start time.Time
+	//重启之后从协议日志找回的轮次，提交的数据可能在重启之前已经写入
+	recovered bool
+	//coordinator写入自己的数据之后通知watcher的事件
+	events []*pb.WatchEvent
+}
+
+func newRoundState(index uint64, msgs []cache.Msg) *roundState {
+	return &roundState{
+		index:     index,
+		msgs:      msgs,
+		proposals: map[string][]cache.Msg{},
+		asked:     map[string]bool{},
+		answered:  map[string]bool{},
 	}
+}
 
-	//preCommit
-	for _, follower := range s.Followers {
-		if s.Config.CommitType == THREE_PHASE {
-			ctx, _ = context.WithTimeout(ctx, time.Duration(s.Config.Timeout)*time.Millisecond)
-		}
-		response, err = follower.Precommit(ctx, &pb.PrecommitRequest{Index: s.Height})
-		if err != nil {
-			return &pb.Response{Type: pb.Type_NACK}, nil
-		}
-		if response.Type != pb.Type_ACK {
-			return nil, status.Error(codes.Internal, "follower not acknowledged msg")
-		}
+func (s *Server) roundState(id protocol.Round) *roundState {
+	s.roundsMu.Lock()
+	defer s.roundsMu.Unlock()
+	return s.rounds[id]
+}
+
+func phaseRequest(k protocol.Kind) bool {
+	return k == protocol.Propose || k == protocol.Resolve || k == protocol.Precommit
+}
+
+//asked记下coordinator在这一轮的当前阶段把请求发给了谁
+func (s *Server) asked(m protocol.Message) {
+	if !phaseRequest(m.Kind) {
+		return
 	}
+	s.roundsMu.Lock()
+	defer s.roundsMu.Unlock()
+	rs := s.rounds[m.Round]
+	if rs == nil {
+		return
+	}
+	if m.Kind == protocol.Precommit && !rs.precommit {
+		rs.precommit, rs.asked, rs.answered, rs.failures = true, map[string]bool{}, map[string]bool{}, nil
+	}
+	rs.asked[m.To] = true
+}
 
-	//从cache中获取key和value
-	key, value, ok := s.NodeCache.Get(s.Height)
-	if !ok {
-		return nil, status.Error(codes.Internal, "can't to find msg in the coordinator's cache")
+//answered记下参与者在当前阶段的回应，拒绝的原因留到回滚时汇总
+func (s *Server) answered(m protocol.Message, resp *pb.Response) {
+	if !phaseRequest(m.Kind) {
+		return
+	}
+	s.roundsMu.Lock()
+	defer s.roundsMu.Unlock()
+	rs := s.rounds[m.Round]
+	if rs == nil || (m.Kind == protocol.Precommit) != rs.precommit || rs.answered[m.To] {
+		return
 	}
-	//将数据存储起来，coordinator会保存一份，follower也会保存一份
-	if err = s.DB.Put(key, value); err != nil {
-		return &pb.Response{Type: pb.Type_NACK}, status.Error(codes.Internal, "failed to save msg on coordinator")
+	rs.answered[m.To] = true
+	if resp.Type == pb.Type_NACK {
+		rs.failures = append(rs.failures, resp)
 	}
+}
 
-	//这里是有问题的，如果上面两步操作有延迟，那么请求就会失败。需要指定超时时间，这点设计的很不好
-	//time.Sleep(time.Duration(100) * time.Millisecond)
+//runRound在一个新的高度上只和targets跑一轮协议，msgs是coordinator自己要写入的数据，
+//partial表示每个参与者只收到自己的那部分数据。协议由coordinator节点执行，这里等它做出决定。
+//不同的轮次使用不同的高度同时进行，写同一个key的轮次由参与者上的key锁排开
+func (s *Server) runRound(targets []target, msgs []cache.Msg, partial bool) roundResult {
+	rs := newRoundState(0, msgs)
+	//只有一个参与者时两阶段没有意义，让它直接提交
+	rs.partial, rs.onePhase, rs.done = partial, len(targets) == 1, make(chan roundResult, 1)
+	participants := make([]string, 0, len(targets))
+	for _, t := range targets {
+		participants = append(participants, t.follower.Addr())
+		rs.proposals[t.follower.Addr()] = t.msgs
+	}
 
-	//commit
-	for _, follower := range s.Followers {
-		//commit的逻辑失败的话需要回滚，这个操作由follower自己实现
-		response, err = follower.Commit(ctx, &pb.CommitRequest{Index: s.Height})
-		if err != nil {
-			return &pb.Response{Type: pb.Type_NACK}, nil
+	p := s.coordinatorNode
+	p.mu.Lock()
+	c := p.node.(*protocol.Coordinator)
+	id := c.NewRound()
+	p.mu.Unlock()
+	//写同一个key的轮次等前一轮做出决定再开始，coordinator自己的数据按高度的顺序写入
+	txid := id.String()
+	if !s.writing.wait(txid, msgKeys(msgs), s.drainCh) {
+		return roundResult{err: errDraining}
+	}
+	p.mu.Lock()
+	//Stop之后不再开始新的轮次，已经开始的轮次由drain等它们做出决定
+	if s.isDraining() {
+		p.mu.Unlock()
+		s.writing.release(txid)
+		return roundResult{err: errDraining}
+	}
+	//高度和开始时间在同一把锁下分配，越老的事务高度越低
+	index := s.next
+	s.next++
+	rs.index, rs.start = index, time.Now()
+	s.roundsMu.Lock()
+	s.rounds[id] = rs
+	s.roundsMu.Unlock()
+	c.Begin(&env{s: s, p: p}, id, index, participants)
+	p.mu.Unlock()
+	defer func() {
+		metrics.Rounds.Add(1)
+		metrics.RoundPuts.Add(int64(len(msgs)))
+		metrics.RoundLatency.ObserveDuration(time.Since(rs.start))
+	}()
+
+	select {
+	case r := <-rs.done:
+		return r
+	case <-s.stopCh:
+		//一阶段提交不知道参与者有没有提交时一直问到停止
+		return roundResult{resp: &pb.Response{Type: pb.Type_NACK}, err: status.Errorf(codes.Unavailable, "transaction on height %d is in doubt at shutdown", index)}
+	}
+}
+
+//roundDecided把coordinator节点的决定交给等待这一轮的调用方，presumed变种在提交的决定落盘之后才写入coordinator自己的数据，
+//高度按顺序推进，回滚的高度是空洞
+func (s *Server) roundDecided(id protocol.Round, index uint64, o protocol.Outcome) {
+	s.roundsMu.Lock()
+	rs := s.rounds[id]
+	delete(s.rounds, id)
+	s.roundsMu.Unlock()
+	if rs == nil {
+		return
+	}
+	txid := id.String()
+	var r roundResult
+	switch {
+	case o == protocol.Aborted:
+		s.journal.record(pb.JournalEvent_ABORTED, index, "", txid, "")
+		r = s.abortResult(rs)
+	case rs.result != nil:
+		r = *rs.result
+	default:
+		//重启之前可能已经写入了
+		if presumed(s.Config.CommitType) && !rs.onePhase && !(rs.recovered && s.writtenAt(index)) {
+			events, err := s.applyAt(index, rs.msgs)
+			if err != nil {
+				log.Error(fmt.Sprintf("failed to apply committed height %d: %v", index, err))
+				r = roundResult{resp: &pb.Response{Type: pb.Type_NACK}, err: voteError("commit", s.nack(pb.Reason_STORAGE_ERROR, "failed to save msg on coordinator: %v", err))}
+				break
+			}
+			rs.events = events
 		}
-		if response.Type != pb.Type_ACK {
-			return nil, status.Error(codes.Internal, "follower not acknowledged msg")
+		s.journal.record(pb.JournalEvent_COMMITTED, index, "", txid, "")
+		r = roundResult{
+			resp: &pb.Response{
+				Type:  pb.Type_ACK,
+				Index: index,
+			},
+			decided: true,
 		}
 	}
+	s.writing.release(txid)
+	s.settle(index, &settlement{committed: o == protocol.Committed, apply: eventsOf(rs.events)})
+	if rs.done != nil {
+		rs.done <- r
+	}
+}
 
-	atomic.AddUint64(&s.Height, 1)
+//abortResult把回滚的原因汇总成返回给客户端的错误：参与者的拒绝，没有拒绝时是到超时还没有回应的参与者
+func (s *Server) abortResult(rs *roundState) roundResult {
+	if rs.result != nil {
+		return *rs.result
+	}
+	s.roundsMu.Lock()
+	defer s.roundsMu.Unlock()
+	votes := rs.failures
+	if len(votes) == 0 {
+		addrs := make([]string, 0, len(rs.asked))
+		for addr := range rs.asked {
+			if !rs.answered[addr] {
+				addrs = append(addrs, addr)
+			}
+		}
+		sort.Strings(addrs)
+		for _, addr := range addrs {
+			votes = append(votes, &pb.Response{Type: pb.Type_NACK, Reason: pb.Reason_TIMEOUT, Message: "timed out", Node: addr})
+		}
+	}
+	if rs.precommit {
+		return roundResult{resp: &pb.Response{Type: pb.Type_NACK}, err: s.precommitFailed(voteError("precommit", votes...))}
+	}
+	return roundResult{err: rolledBack(voteError("propose", votes...))}
+}
 
-	return &pb.Response{
-		Type: pb.Type_ACK,
-	}, nil
+//proposal生成发给一个参与者的提案，单条写入用Key/Value，合并提交时用Entries；
+//重启之后询问一阶段提交的结果时不带数据，这一轮已经有了决定时返回nil
+func (s *Server) proposal(m protocol.Message) *pb.ProposeRequest {
+	rs := s.roundState(m.Round)
+	if rs == nil {
+		return nil
+	}
+	proposal := &pb.ProposeRequest{
+		CommitType:  commitTypeOf(s.Config.CommitType),
+		Index:       m.Height,
+		Txid:        m.Round.String(),
+		Sharded:     rs.partial,
+		OnePhase:    m.OnePhase,
+		Resolve:     m.Kind == protocol.Resolve,
+		Coordinator: s.Addr,
+	}
+	if proposal.Resolve {
+		return proposal
+	}
+	proposal.Timestamp = uint64(rs.start.UnixNano())
+	proposal.Resolved = atomic.LoadUint64(&s.Height)
+	msgs := rs.proposals[m.To]
+	if len(msgs) == 1 {
+		proposal.Key, proposal.Value, proposal.Delete = msgs[0].Key, msgs[0].Value, msgs[0].Delete
+	} else {
+		for _, m := range msgs {
+			proposal.Entries = append(proposal.Entries, &pb.Entry{Key: m.Key, Value: m.Value, Delete: m.Delete})
+		}
+	}
+	return proposal
 }
 
 func (s *Server) Get(ctx context.Context, msg *pb.Msg) (*pb.Value, error) {
+	if s.shards != nil {
+		return s.getFromShard(ctx, msg)
+	}
 	value, err := s.DB.Get(msg.Key)
 	if err != nil {
 		return nil, err
 	}
 	return &pb.Value{
 		Value: value,
+		Found: value != nil,
 	}, nil
 }
 
 func (s *Server) NodeInfo(ctx context.Context, empty *empty.Empty) (*pb.Info, error) {
 	return &pb.Info{
-		Height: s.Height,
+		Height:      atomic.LoadUint64(&s.Height),
+		Coordinator: s.Config.Coordinator,
+		Role:        s.Config.Role,
 	}, nil
 }
+
+//单次Scan最多返回的条数，limit为0或超过该值时使用它
+const maxScanLimit = 1000
+
+func (s *Server) Scan(request *pb.ScanRequest, stream pb.Commit_ScanServer) error {
+	start, end := request.Start, request.End
+	if request.Prefix != "" {
+		start, end = request.Prefix, prefixEnd(request.Prefix)
+	}
+	//续传时从上一页最后一个key之后开始
+	if request.Token != "" {
+		last, err := decodeScanToken(request.Token)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "invalid scan token")
+		}
+		start = last + "\x00"
+	}
+
+	limit := int(request.Limit)
+	if limit == 0 || limit > maxScanLimit {
+		limit = maxScanLimit
+	}
+	//多取一条，用来判断后面还有没有数据
+	kvs, err := s.DB.Scan(start, end, limit+1)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	more := len(kvs) > limit
+	if more {
+		kvs = kvs[:limit]
+	}
+	for i, kv := range kvs {
+		rsp := &pb.ScanResponse{Key: kv.Key, Value: []byte(kv.Value)}
+		if more && i == len(kvs)-1 {
+			rsp.Token = encodeScanToken(kv.Key)
+		}
+		if err := stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//返回比所有以prefix开头的key都大的最小key：去掉末尾的0xff再把最后一个字节加一，prefix全是0xff时返回空，表示没有上限。
+//结果不一定是合法的UTF-8，数据库按字节比较
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+func encodeScanToken(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeScanToken(token string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+//每次从数据库回放的高度个数
+const watchReplayBatch = 500
+
+func (s *Server) Watch(request *pb.WatchRequest, stream pb.Commit_WatchServer) error {
+	//先订阅再回放，boundary之前的从数据库回放，之后的实时推送，两者之间不会重叠也不会遗漏
+	w, boundary := s.watchHub.subscribe(request.Prefix)
+	defer s.watchHub.unsubscribe(w)
+
+	if compacted := atomic.LoadUint64(&s.compacted); request.FromHeight < compacted {
+		return status.Errorf(codes.OutOfRange, "height %d has been compacted, snapshot is at height %d", request.FromHeight, compacted)
+	}
+
+	//toHeight不为0时只推送到这个高度（不包含）为止
+	to := request.ToHeight
+	replayTo := boundary
+	if to != 0 && to < replayTo {
+		replayTo = to
+	}
+	start, end := request.Prefix, prefixEnd(request.Prefix)
+	for from := request.FromHeight; from < replayTo; from += watchReplayBatch {
+		upper := from + watchReplayBatch
+		if upper > replayTo {
+			upper = replayTo
+		}
+		kvs, err := s.DB.Since(from, upper, start, end)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		//读的时候这一批可能正在被压缩，结果会有缺口
+		if compacted := atomic.LoadUint64(&s.compacted); from < compacted {
+			return status.Errorf(codes.OutOfRange, "height %d has been compacted, snapshot is at height %d", from, compacted)
+		}
+		for _, kv := range kvs {
+			if err := stream.Send(kvToEvent(kv)); err != nil {
+				return err
+			}
+		}
+	}
+	if to != 0 && to <= boundary {
+		return nil
+	}
+
+	for {
+		select {
+		case event := <-w.events:
+			if event.Index < request.FromHeight {
+				continue
+			}
+			if to != 0 && event.Index >= to {
+				return nil
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-w.lagged:
+			//把已经缓存的完整高度推完再断开
+			for {
+				select {
+				case event := <-w.events:
+					if event.Index < request.FromHeight {
+						continue
+					}
+					if to != 0 && event.Index >= to {
+						return nil
+					}
+					if err := stream.Send(event); err != nil {
+						return err
+					}
+				default:
+					return status.Errorf(codes.ResourceExhausted, "watcher is too slow, resume from the last received height")
+				}
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/2pc/cache"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//settlement是一个高度的结果：提交的高度轮到时写入并通知watcher，回滚的高度是空洞
+type settlement struct {
+	committed bool
+	//轮到这个高度时调用，返回通知watcher的事件；coordinator在做出决定时已经写入，这里只返回事件
+	apply func() ([]*pb.WatchEvent, error)
+	//这个高度轮到之后，或者已经被追数据和快照越过时调用，比如释放key锁
+	done func()
+}
+
+func (st *settlement) finish() {
+	if st.done != nil {
+		st.done()
+	}
+}
+
+func eventsOf(events []*pb.WatchEvent) func() ([]*pb.WatchEvent, error) {
+	return func() ([]*pb.WatchEvent, error) {
+		return events, nil
+	}
+}
+
+//settle记下一个高度的结果，已经有结果的高度不变，然后按高度的顺序推进：
+//同时进行的轮次可能先后颠倒着做出决定，高度只在前面的高度都有了结果之后才越过它
+func (s *Server) settle(index uint64, st *settlement) {
+	s.settledMu.Lock()
+	defer s.settledMu.Unlock()
+	if _, ok := s.settled[index]; ok || index < atomic.LoadUint64(&s.Height) {
+		st.finish()
+		return
+	}
+	s.settled[index] = st
+	s.advanceSettled()
+}
+
+//unsettle去掉一个空洞，coordinator重启之后在这个高度上开始了新的一轮；返回这个高度是否已经提交，比如追数据时已经收到
+func (s *Server) unsettle(index uint64) bool {
+	s.settledMu.Lock()
+	defer s.settledMu.Unlock()
+	st, ok := s.settled[index]
+	if ok && !st.committed {
+		delete(s.settled, index)
+	}
+	return ok && st.committed
+}
+
+//advanceSettled越过有了结果的高度，空洞只在后面有提交的高度时才越过：
+//重启之后从数据库读出的高度不会越过末尾的空洞，coordinator复用这些高度时参与者还没有越过它们
+func (s *Server) advanceSettled() {
+	height := atomic.LoadUint64(&s.Height)
+	//追数据和安装快照越过的高度
+	for index, st := range s.settled {
+		if index < height {
+			delete(s.settled, index)
+			st.finish()
+		}
+	}
+	for {
+		end := height
+		for ; ; end++ {
+			st, ok := s.settled[end]
+			if !ok {
+				return
+			}
+			if st.committed {
+				break
+			}
+		}
+		var events []*pb.WatchEvent
+		if apply := s.settled[end].apply; apply != nil {
+			var err error
+			if events, err = apply(); err != nil {
+				//留着这个高度，下一次推进时再写
+				log.Error(fmt.Sprintf("failed to apply committed height %d: %v", end, err))
+				return
+			}
+		}
+		for index := height; index <= end; index++ {
+			st := s.settled[index]
+			delete(s.settled, index)
+			st.finish()
+		}
+		s.watchHub.publish(events...)
+		height = end + 1
+		atomic.StoreUint64(&s.Height, height)
+		s.watchHub.skip(height)
+	}
+}
+
+//settledBefore判断index之前的高度是否都已经写入，中间只剩空洞，这时本地的值就是index之前的值
+func (s *Server) settledBefore(index uint64) bool {
+	s.settledMu.Lock()
+	defer s.settledMu.Unlock()
+	for height := atomic.LoadUint64(&s.Height); height < index; height++ {
+		if st, ok := s.settled[height]; !ok || st.committed {
+			return false
+		}
+	}
+	return true
+}
+
+//unknownBefore判断target之前有没有本地不知道结果的高度：既没有结果，也没有在等决定的轮次，
+//比如提案或者回滚没有送到这里。只在participantNode.mu下调用
+func (s *Server) unknownBefore(target uint64) bool {
+	waiting := map[uint64]bool{}
+	for _, b := range s.branches {
+		waiting[b.index] = true
+	}
+	s.settledMu.Lock()
+	defer s.settledMu.Unlock()
+	for height := atomic.LoadUint64(&s.Height); height < target; height++ {
+		if _, ok := s.settled[height]; !ok && !waiting[height] {
+			return true
+		}
+	}
+	return false
+}
+
+//applyAt写入一个高度上决定提交的数据，返回通知watcher的事件
+func (s *Server) applyAt(index uint64, msgs []cache.Msg) ([]*pb.WatchEvent, error) {
+	events := make([]*pb.WatchEvent, 0, len(msgs))
+	for _, m := range msgs {
+		event, err := apply(s.DB, index, m)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+//writtenAt判断这个高度上是否已经写入过数据，比如重启之后重新报告的决定、重发的一阶段提交
+func (s *Server) writtenAt(index uint64) bool {
+	kvs, err := s.DB.Since(index, index+1, "", "")
+	return err == nil && len(kvs) > 0
+}
+
+//settleAt记下参与者在一个高度上的结果：分片的follower上高度有空洞，不能按顺序推进，提交时直接写入
+func (s *Server) settleAt(index uint64, st *settlement) error {
+	if !s.isSharded() {
+		s.settle(index, st)
+		return nil
+	}
+	defer st.finish()
+	if !st.committed {
+		return nil
+	}
+	events, err := st.apply()
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		s.watchHub.skip(index + 1)
+	}
+	s.watchHub.publish(events...)
+	s.advance(index)
+	return nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/client"
+	"github.com/sysphusking/dsts/2pc/config"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/shard"
+)
+
+//target是一轮中的一个参与者和它要写入的数据
+type target struct {
+	follower *client.CommitClient
+	msgs     []cache.Msg
+}
+
+//initShards为分片里的follower建立连接，分片里出现但不在Followers里的地址也会加入Followers
+func (s *Server) initShards(conf *config.Config) error {
+	m, err := shard.New(conf.Shards, conf.HashSlots)
+	if err != nil {
+		return err
+	}
+	known := map[*client.CommitClient]bool{}
+	for _, follower := range s.Followers {
+		known[follower] = true
+	}
+	s.shardFollowers = make([][]*client.CommitClient, m.Len())
+	for i := 0; i < m.Len(); i++ {
+		for _, addr := range m.Shard(i).Followers {
+			cli, err := s.clientFor(addr)
+			if err != nil {
+				return err
+			}
+			if !known[cli] {
+				known[cli] = true
+				s.Followers = append(s.Followers, cli)
+			}
+			s.shardFollowers[i] = append(s.shardFollowers[i], cli)
+		}
+	}
+	s.shards = m
+	return nil
+}
+
+//route把这一轮的数据按分片分给参与者，没有分片时每个follower都写入全部数据
+func (s *Server) route(msgs []cache.Msg) ([]target, error) {
+	if s.shards == nil {
+		targets := make([]target, 0, len(s.Followers))
+		for _, follower := range s.Followers {
+			targets = append(targets, target{follower: follower, msgs: msgs})
+		}
+		return targets, nil
+	}
+	var targets []target
+	seen := map[*client.CommitClient]int{}
+	for _, m := range msgs {
+		i, err := s.shards.Locate(m.Key)
+		if err != nil {
+			//和hook拒绝一样只影响这一个写入，合并提交时会逐个重试
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		for _, follower := range s.shardFollowers[i] {
+			j, ok := seen[follower]
+			if !ok {
+				j = len(targets)
+				seen[follower] = j
+				targets = append(targets, target{follower: follower})
+			}
+			targets[j].msgs = append(targets[j].msgs, m)
+		}
+	}
+	return targets, nil
+}
+
+//getFromShard从拥有这个key的分片读取，依次尝试分片里的follower
+func (s *Server) getFromShard(ctx context.Context, msg *pb.Msg) (*pb.Value, error) {
+	i, err := s.shards.Locate(msg.Key)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	for _, follower := range s.shardFollowers[i] {
+		//直接发给这个follower，不经过coordinator发现
+		var value *pb.Value
+		if value, err = follower.Connection.Get(ctx, msg); err == nil {
+			return value, nil
+		}
+	}
+	return nil, status.Errorf(codes.Unavailable, "no follower of shard %s is available: %v", s.shards.Shard(i).Name, err)
+}
+
+//分片的follower上高度会有空洞，高度只表示写入过的最大高度
+func (s *Server) isSharded() bool {
+	return atomic.LoadInt32(&s.sharded) == 1
+}
+
+//advance把高度推进到index之后，不会回退
+func (s *Server) advance(index uint64) {
+	for {
+		height := atomic.LoadUint64(&s.Height)
+		if height > index || atomic.CompareAndSwapUint64(&s.Height, height, index+1) {
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sysphusking/dsts/2pc/db"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+	"github.com/sysphusking/dsts/2pc/snapshot"
+)
+
+const (
+	//快照目录下保留的快照文件个数
+	snapshotRetain = 2
+	//传输快照时每一块的大小
+	snapshotChunkSize = 64 * 1024
+)
+
+//定时做快照，做完后把快照高度之前的旧数据压缩掉
+func (s *Server) runSnapshots() {
+	ticker := time.NewTicker(time.Duration(s.Config.SnapshotInterval) * time.Millisecond)
+	defer ticker.Stop()
+	var last uint64
+	for {
+		select {
+		case <-ticker.C:
+			height := atomic.LoadUint64(&s.Height)
+			if height == last {
+				continue
+			}
+			if _, err := s.TakeSnapshot(height); err != nil {
+				log.Error(fmt.Sprintf("failed to take snapshot at height %d: %v", height, err))
+				continue
+			}
+			//先发布压缩高度再删除，正在回放的Watch读完每一批后会重新检查，读到正在删除的数据时返回OutOfRange
+			atomic.StoreUint64(&s.compacted, height)
+			if err := s.DB.Compact(height); err != nil {
+				log.Error(fmt.Sprintf("failed to compact before height %d: %v", height, err))
+				continue
+			}
+			last = height
+		case <-s.drainCh:
+			return
+		}
+	}
+}
+
+//TakeSnapshot把height之前的数据写成快照文件，返回文件路径
+func (s *Server) TakeSnapshot(height uint64) (string, error) {
+	kvs, err := s.DB.At(height)
+	if err != nil {
+		return "", err
+	}
+	path, err := snapshot.Write(s.Config.SnapshotDir, toSnapshot(height, kvs))
+	if err != nil {
+		return "", err
+	}
+	log.Info(fmt.Sprintf("snapshot at height %d written to %s", height, path))
+	return path, snapshot.Prune(s.Config.SnapshotDir, snapshotRetain)
+}
+
+//Backup把当前数据写到指定的快照文件
+func (s *Server) Backup(path string) error {
+	height := atomic.LoadUint64(&s.Height)
+	kvs, err := s.DB.At(height)
+	if err != nil {
+		return err
+	}
+	return snapshot.WriteFile(path, toSnapshot(height, kvs))
+}
+
+//Restore用快照文件替换当前数据
+func (s *Server) Restore(path string) error {
+	snap, err := snapshot.Read(path)
+	if err != nil {
+		return err
+	}
+	return s.install(snap)
+}
+
+func (s *Server) install(snap *snapshot.Snapshot) error {
+	kvs := make([]*db.KV, 0, len(snap.Entries))
+	for _, e := range snap.Entries {
+		kvs = append(kvs, &db.KV{Key: e.Key, Value: string(e.Value), CommitIndex: e.Index})
+	}
+	if err := s.DB.Restore(kvs); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&s.Height, snap.Height)
+	atomic.StoreUint64(&s.compacted, snap.Height)
+	s.watchHub.reset(snap.Height)
+	log.Info(fmt.Sprintf("restored snapshot at height %d", snap.Height))
+	return nil
+}
+
+//Snapshot把最新的快照文件原样传给调用方，文件中带有校验和，接收方落盘后自行校验
+func (s *Server) Snapshot(_ *empty.Empty, stream pb.Commit_SnapshotServer) error {
+	path, err := snapshot.Latest(s.Config.SnapshotDir)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if path == "" {
+		return status.Error(codes.NotFound, "no snapshot available")
+	}
+	snap, err := snapshot.Read(path)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer f.Close()
+
+	buf := make([]byte, snapshotChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.SnapshotChunk{Height: snap.Height, Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+//加载本地最新的快照和数据库里的高度，重启后从这里继续
+func (s *Server) loadHeight() error {
+	height, err := s.DB.Height()
+	if err != nil {
+		return err
+	}
+	path, err := snapshot.Latest(s.Config.SnapshotDir)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		snap, err := snapshot.Read(path)
+		if err != nil {
+			return err
+		}
+		//快照之前的数据可能已经被压缩掉了
+		s.compacted = snap.Height
+		if snap.Height > height {
+			height = snap.Height
+		}
+	}
+	s.Height = height
+	s.watchHub.reset(height)
+	return nil
+}
+
+func toSnapshot(height uint64, kvs []*db.KV) *snapshot.Snapshot {
+	snap := &snapshot.Snapshot{Height: height, Entries: make([]snapshot.Entry, 0, len(kvs))}
+	for _, kv := range kvs {
+		snap.Entries = append(snap.Entries, snapshot.Entry{Key: kv.Key, Value: []byte(kv.Value), Index: kv.CommitIndex})
+	}
+	return snap
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/client"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//超过这个时间没有提交也没有回滚的事务会被丢掉
+const txTTL = 10 * time.Minute
+
+//transaction是应用通过Begin开始的事务，参与者和它们要写的数据由应用登记
+type transaction struct {
+	id      string
+	addrs   []string
+	msgs    map[string][]cache.Msg
+	touched time.Time
+	//已经做出提交决定的结果，重复的CommitTx直接返回它
+	result *roundResult
+	mu     sync.Mutex
+}
+
+type txTable struct {
+	txs map[string]*transaction
+	seq uint64
+	mu  sync.Mutex
+}
+
+func newTxTable() *txTable {
+	return &txTable{txs: map[string]*transaction{}}
+}
+
+func (t *txTable) begin() *transaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for id, tx := range t.txs {
+		if now.Sub(tx.touched) > txTTL {
+			delete(t.txs, id)
+		}
+	}
+	t.seq++
+	tx := &transaction{
+		id:      fmt.Sprintf("tx-%d-%d", now.UnixNano(), t.seq),
+		msgs:    map[string][]cache.Msg{},
+		touched: now,
+	}
+	t.txs[tx.id] = tx
+	return tx
+}
+
+func (t *txTable) get(id string) (*transaction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tx, ok := t.txs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "transaction %s not found", id)
+	}
+	tx.touched = time.Now()
+	return tx, nil
+}
+
+func (t *txTable) remove(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.txs, id)
+}
+
+func (s *Server) Begin(ctx context.Context, _ *empty.Empty) (*pb.Tx, error) {
+	if s.Config.Role != "coordinator" {
+		return nil, status.Error(codes.FailedPrecondition, "transactions can only be started on the coordinator")
+	}
+	if s.isDraining() {
+		return nil, errDraining
+	}
+	return &pb.Tx{Txid: s.txs.begin().id}, nil
+}
+
+//AddParticipant登记一个参与者和它在这个事务里要写的数据
+func (s *Server) AddParticipant(ctx context.Context, request *pb.ParticipantRequest) (*pb.Response, error) {
+	if request.Addr == "" {
+		return nil, status.Error(codes.InvalidArgument, "participant address is empty")
+	}
+	tx, err := s.txs.get(request.Txid)
+	if err != nil {
+		return nil, err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.result != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "transaction %s has been committed", tx.id)
+	}
+	if _, ok := tx.msgs[request.Addr]; !ok {
+		tx.addrs = append(tx.addrs, request.Addr)
+	}
+	//同一个key只保留最后一次登记的写入，客户端重试同一个请求时不会重复写入
+	for _, e := range request.Entries {
+		tx.msgs[request.Addr] = setMsg(tx.msgs[request.Addr], cache.Msg{Key: e.Key, Value: e.Value, Delete: e.Delete})
+	}
+	return &pb.Response{Type: pb.Type_ACK}, nil
+}
+
+func setMsg(msgs []cache.Msg, m cache.Msg) []cache.Msg {
+	for i := range msgs {
+		if msgs[i].Key == m.Key {
+			msgs[i] = m
+			return msgs
+		}
+	}
+	return append(msgs, m)
+}
+
+//CommitTx只在登记过的参与者之间跑一轮协议，在做出决定之前失败时事务保持打开，可以再次提交
+func (s *Server) CommitTx(ctx context.Context, request *pb.Tx) (*pb.Response, error) {
+	tx, err := s.txs.get(request.Txid)
+	if err != nil {
+		return nil, err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.result != nil {
+		return tx.result.resp, tx.result.err
+	}
+	if len(tx.addrs) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "transaction %s has no participant", tx.id)
+	}
+
+	targets := make([]target, 0, len(tx.addrs))
+	for _, addr := range tx.addrs {
+		var cli *client.CommitClient
+		if cli, err = s.clientFor(addr); err != nil {
+			return nil, status.Errorf(codes.Unavailable, "failed to connect participant %s: %v", addr, err)
+		}
+		targets = append(targets, target{follower: cli, msgs: tx.msgs[addr]})
+	}
+	//参与者的数据只写在参与者上，coordinator只推进高度，其他follower追数据时把这个高度当成空洞
+	r := s.runRound(targets, nil, true)
+	if r.decided {
+		tx.result = &r
+	}
+	return r.resp, r.err
+}
+
+func (s *Server) RollbackTx(ctx context.Context, request *pb.Tx) (*pb.Response, error) {
+	tx, err := s.txs.get(request.Txid)
+	if err != nil {
+		return nil, err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.result != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "transaction %s has been committed", tx.id)
+	}
+	//还没有跑过协议，参与者上没有任何状态，直接丢掉
+	s.txs.remove(tx.id)
+	return &pb.Response{Type: pb.Type_ACK}, nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/db"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+//每个watcher最多缓存的事件数，消费太慢被填满时会断开，由客户端从最后收到的index续传
+const watchBufferSize = 256
+
+type watcher struct {
+	prefix string
+	events chan *pb.WatchEvent
+	//缓冲区满了之后会被关闭
+	lagged chan struct{}
+	once   sync.Once
+}
+
+func (w *watcher) drop() {
+	w.once.Do(func() { close(w.lagged) })
+}
+
+//watchHub把提交路径上产生的事件分发给所有的watcher
+type watchHub struct {
+	watchers map[*watcher]struct{}
+	//下一个要发布的高度，比它小的高度的数据都已经落库
+	next uint64
+	mu   sync.Mutex
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{watchers: map[*watcher]struct{}{}}
+}
+
+//subscribe返回的boundary之前的事件需要从数据库回放，之后的事件都会推送给watcher
+func (h *watchHub) subscribe(prefix string) (w *watcher, boundary uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w = &watcher{
+		prefix: prefix,
+		events: make(chan *pb.WatchEvent, watchBufferSize),
+		lagged: make(chan struct{}),
+	}
+	h.watchers[w] = struct{}{}
+	return w, h.next
+}
+
+//启动或者安装快照之后重置发布高度
+func (h *watchHub) reset(height uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next = height
+}
+
+func (h *watchHub) unsubscribe(w *watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.watchers, w)
+}
+
+//publish发布同一个高度上的所有事件，一个高度的事件必须一次发布完
+func (h *watchHub) publish(events ...*pb.WatchEvent) {
+	if len(events) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		matched := make([]*pb.WatchEvent, 0, len(events))
+		for _, event := range events {
+			if strings.HasPrefix(event.Key, w.prefix) {
+				matched = append(matched, event)
+			}
+		}
+		//同一个高度的事件要么全部推给watcher，要么一个都不推，这样续传时不会只收到一半
+		if cap(w.events)-len(w.events) < len(matched) {
+			//不阻塞提交路径，直接把这个watcher断开
+			delete(h.watchers, w)
+			w.drop()
+			continue
+		}
+		for _, event := range matched {
+			w.events <- event
+		}
+	}
+	if index := events[0].Index + 1; index > h.next {
+		h.next = index
+	}
+}
+
+//skip推进发布高度，用在没有任何事件的高度上，比如只写在登记参与者上的事务，
+//这样Watch的回放边界会越过它，追数据的follower不会一直等这个高度的事件
+func (h *watchHub) skip(height uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if height > h.next {
+		h.next = height
+	}
+}
+
+//把缓存中的写操作落库，返回对应的watch事件
+func apply(database db.Database, index uint64, m cache.Msg) (*pb.WatchEvent, error) {
+	if m.Delete {
+		if err := database.Delete(index, m.Key); err != nil {
+			return nil, err
+		}
+		return &pb.WatchEvent{Type: pb.EventType_DELETE, Key: m.Key, Index: index}, nil
+	}
+	if err := database.Put(index, m.Key, m.Value); err != nil {
+		return nil, err
+	}
+	return &pb.WatchEvent{Type: pb.EventType_PUT, Key: m.Key, Value: m.Value, Index: index}, nil
+}
+
+func kvToEvent(kv *db.KV) *pb.WatchEvent {
+	if kv.Tombstone {
+		return &pb.WatchEvent{Type: pb.EventType_DELETE, Key: kv.Key, Index: kv.CommitIndex}
+	}
+	return &pb.WatchEvent{Type: pb.EventType_PUT, Key: kv.Key, Value: []byte(kv.Value), Index: kv.CommitIndex}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/db"
+	pb "github.com/sysphusking/dsts/2pc/proto"
+)
+
+const (
+	//参与者直接通过gorm写入，commit时才落库
+	BACKEND_GORM = "gorm"
+	//参与者在投票之前XA PREPARE，commit时XA COMMIT
+	BACKEND_XA = "xa"
+)
+
+func toKVs(index uint64, msgs []cache.Msg) []*db.KV {
+	kvs := make([]*db.KV, 0, len(msgs))
+	for _, m := range msgs {
+		kv := &db.KV{Key: m.Key, CommitIndex: index, Tombstone: m.Delete}
+		if !m.Delete {
+			kv.Value = string(m.Value)
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
+
+func toEvents(index uint64, msgs []cache.Msg) []*pb.WatchEvent {
+	events := make([]*pb.WatchEvent, 0, len(msgs))
+	for _, m := range msgs {
+		if m.Delete {
+			events = append(events, &pb.WatchEvent{Type: pb.EventType_DELETE, Key: m.Key, Index: index})
+			continue
+		}
+		events = append(events, &pb.WatchEvent{Type: pb.EventType_PUT, Key: m.Key, Value: m.Value, Index: index})
+	}
+	return events
+}
+
+//xaPrepare在投票之前把数据写进这个事务的XA分支并PREPARE，同一个事务之前没有回滚掉的分支先回滚
+func (s *Server) xaPrepare(owner string, index uint64, msgs []cache.Msg) error {
+	if s.xa.Prepared(owner) {
+		if err := s.xa.Rollback(owner); err != nil {
+			return err
+		}
+	}
+	return s.xa.Prepare(owner, toKVs(index, msgs))
+}
+
+//commitBranch提交已经决定提交的数据，返回通知watcher的事件。XA后端提交PREPARE过的分支，分支不存在说明崩溃之前已经提交了
+func (s *Server) commitBranch(owner string, index uint64, msgs []cache.Msg) ([]*pb.WatchEvent, error) {
+	if s.xa == nil {
+		return s.applyAt(index, msgs)
+	}
+	if !s.xa.Prepared(owner) {
+		return nil, nil
+	}
+	if err := s.xa.Commit(owner); err != nil {
+		return nil, err
+	}
+	//重启之后找回的分支没有数据，这时没有事件
+	return toEvents(index, msgs), nil
+}
+
+//commitOnePhase写入一阶段提交的数据，XA后端用XA COMMIT ONE PHASE，整轮数据在一个数据库事务里写入。
+//投票时就要写入，不等前面的高度，通知watcher仍然按高度的顺序
+func (s *Server) commitOnePhase(owner string, b *branch) error {
+	log.Info(fmt.Sprintf("Committing on height: %d\n", b.index))
+	//重发的提案，这个高度已经提交过了
+	if b.index < atomic.LoadUint64(&s.Height) && s.writtenAt(b.index) {
+		return nil
+	}
+	if s.xa == nil {
+		events, err := s.applyAt(b.index, b.msgs)
+		b.events = events
+		return err
+	}
+	if err := s.xa.CommitOnePhase(owner, toKVs(b.index, b.msgs)); err != nil {
+		return err
+	}
+	b.events = toEvents(b.index, b.msgs)
+	return nil
+}
+
+//rollbackBranch回滚XA分支，gorm后端在commit之前没有写入任何数据
+func (s *Server) rollbackBranch(owner string) error {
+	if s.xa == nil {
+		return nil
+	}
+	return s.xa.Rollback(owner)
+}
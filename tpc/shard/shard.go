@@ -0,0 +1,125 @@
+package shard
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/sysphusking/dsts/2pc/config"
+)
+
+//默认的hash槽个数
+const DefaultHashSlots = 1024
+
+//Map记录每个key属于哪个分片，分片可以按key范围划分，也可以按hash槽划分，两种方式可以混用，
+//hash槽优先于key范围
+type Map struct {
+	shards    []config.Shard
+	hashSlots int
+	//hash槽到分片序号，-1表示没有分配
+	slots []int
+	//按Start排序的范围分片序号
+	ranges []int
+}
+
+func New(shards []config.Shard, hashSlots int) (*Map, error) {
+	if hashSlots <= 0 {
+		hashSlots = DefaultHashSlots
+	}
+	m := &Map{shards: shards, hashSlots: hashSlots, slots: make([]int, hashSlots)}
+	for i := range m.slots {
+		m.slots[i] = -1
+	}
+	for i, sh := range shards {
+		if len(sh.Followers) == 0 {
+			return nil, errors.Errorf("shard %s has no follower", sh.Name)
+		}
+		if len(sh.Slots) == 0 {
+			if sh.End != "" && sh.End <= sh.Start {
+				return nil, errors.Errorf("shard %s has an empty key range", sh.Name)
+			}
+			m.ranges = append(m.ranges, i)
+			continue
+		}
+		for _, slots := range sh.Slots {
+			from, to, err := parseSlots(slots)
+			if err != nil {
+				return nil, errors.Wrapf(err, "shard %s", sh.Name)
+			}
+			if to >= hashSlots {
+				return nil, errors.Errorf("shard %s: slot %d out of range [0, %d)", sh.Name, to, hashSlots)
+			}
+			for slot := from; slot <= to; slot++ {
+				if owner := m.slots[slot]; owner >= 0 {
+					return nil, errors.Errorf("slot %d is assigned to both %s and %s", slot, shards[owner].Name, sh.Name)
+				}
+				m.slots[slot] = i
+			}
+		}
+	}
+
+	sort.Slice(m.ranges, func(a, b int) bool {
+		return shards[m.ranges[a]].Start < shards[m.ranges[b]].Start
+	})
+	for i := 1; i < len(m.ranges); i++ {
+		prev, cur := shards[m.ranges[i-1]], shards[m.ranges[i]]
+		if prev.End == "" || prev.End > cur.Start {
+			return nil, errors.Errorf("key ranges of shard %s and %s overlap", prev.Name, cur.Name)
+		}
+	}
+	return m, nil
+}
+
+//形如"0-511"或者"7"的槽范围
+func parseSlots(s string) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	from, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid slots %q", s)
+	}
+	to := from
+	if len(parts) == 2 {
+		if to, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return 0, 0, errors.Errorf("invalid slots %q", s)
+		}
+	}
+	if from < 0 || to < from {
+		return 0, 0, errors.Errorf("invalid slots %q", s)
+	}
+	return from, to, nil
+}
+
+func (m *Map) Len() int {
+	return len(m.shards)
+}
+
+func (m *Map) Shard(i int) config.Shard {
+	return m.shards[i]
+}
+
+//Slot返回key所在的hash槽
+func (m *Map) Slot(key string) int {
+	return int(crc32.ChecksumIEEE([]byte(key)) % uint32(m.hashSlots))
+}
+
+//Locate返回拥有key的分片序号
+func (m *Map) Locate(key string) (int, error) {
+	if owner := m.slots[m.Slot(key)]; owner >= 0 {
+		return owner, nil
+	}
+	//最后一个Start不大于key的范围分片
+	i := sort.Search(len(m.ranges), func(i int) bool {
+		return m.shards[m.ranges[i]].Start > key
+	})
+	if i > 0 {
+		sh := m.shards[m.ranges[i-1]]
+		if sh.End == "" || key < sh.End {
+			return m.ranges[i-1], nil
+		}
+	}
+	return -1, fmt.Errorf("no shard owns key %q", key)
+}
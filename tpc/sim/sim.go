@@ -0,0 +1,415 @@
+package sim
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/sysphusking/dsts/2pc/protocol"
+)
+
+const coordinatorID = "c"
+
+type Config struct {
+	Mode         protocol.Mode
+	Participants int
+	Rounds       int
+	Timeouts     protocol.Timeouts
+	//消息延迟在[MinDelay, MaxDelay]之间均匀分布，延迟不同的消息会乱序到达
+	MinDelay, MaxDelay time.Duration
+	//消息丢失和重复的概率
+	Loss, Duplicate float64
+	//每处理一个事件后让一个节点崩溃的概率，崩溃的节点在[MinDowntime, MaxDowntime]之后重启
+	CrashRate                float64
+	MinDowntime, MaxDowntime time.Duration
+	//参与者投反对票的概率，投同意票时只读的概率
+	NoVote, ReadOnly float64
+	//模拟的时间上限
+	MaxTime time.Duration
+	//失败时保留的最后几条事件
+	TraceSize int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Mode:         protocol.TwoPhase,
+		Participants: 3,
+		Rounds:       20,
+		Timeouts:     protocol.DefaultTimeouts(),
+		MinDelay:     time.Millisecond,
+		MaxDelay:     50 * time.Millisecond,
+		Loss:         0.05,
+		Duplicate:    0.02,
+		CrashRate:    0.01,
+		MinDowntime:  100 * time.Millisecond,
+		MaxDowntime:  3 * time.Second,
+		NoVote:       0.1,
+		ReadOnly:     0.1,
+		MaxTime:      5 * time.Minute,
+		TraceSize:    2000,
+	}
+}
+
+type Result struct {
+	Seed int64
+	//违反原子性的地方，为空表示这次调度没有问题
+	Violations []string
+	//最后TraceSize条事件
+	Trace     []string
+	Steps     int
+	Time      time.Duration
+	Committed int
+	Aborted   int
+	//模拟结束时还没有决定的轮次，不算违反原子性（2PC在coordinator崩溃时会阻塞）
+	Undecided int
+}
+
+func (r Result) Ok() bool {
+	return len(r.Violations) == 0
+}
+
+type eventKind int
+
+const (
+	deliver eventKind = iota
+	fire
+	restart
+	//coordinator开始下一轮，对应server里的runRound
+	begin
+)
+
+type event struct {
+	at   time.Duration
+	seq  uint64
+	kind eventKind
+	node string
+	//定时器所属的节点在崩溃重启之后就失效了
+	incarnation int
+	msg         protocol.Message
+	timer       protocol.Timer
+}
+
+type queue []*event
+
+func (q queue) Len() int { return len(q) }
+func (q queue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+type node struct {
+	id          string
+	log         *protocol.MemLog
+	impl        protocol.Node
+	up          bool
+	incarnation int
+}
+
+//simulator用一个随机数源决定所有的延迟、丢包、崩溃和投票，同一个seed总是得到同样的调度
+type simulator struct {
+	cfg    Config
+	rnd    *rand.Rand
+	now    time.Duration
+	seq    uint64
+	events queue
+	nodes  []*node
+	byID   map[string]*node
+	//开始过的轮次，每一轮第一个做出的决定和做出它的节点，以及参与者的投票
+	rounds   []protocol.Round
+	outcomes map[protocol.Round]protocol.Outcome
+	decider  map[protocol.Round]string
+	yes      map[protocol.Round]map[string]bool
+	no       map[protocol.Round]bool
+	//每个高度上提交的一轮
+	committed map[uint64]protocol.Round
+	//coordinator提交到的高度，和server里数据库中的高度一样在崩溃之后还在
+	height uint64
+	//coordinator正在跑的一轮，崩溃之后就丢了
+	current *protocol.Round
+	result  Result
+}
+
+type env struct {
+	s *simulator
+	n *node
+}
+
+func (e env) Send(m protocol.Message) {
+	s := e.s
+	if s.rnd.Float64() < s.cfg.Loss {
+		s.trace("drop %s", m)
+		return
+	}
+	copies := 1
+	if s.rnd.Float64() < s.cfg.Duplicate {
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		s.push(&event{at: s.now + s.delay(), kind: deliver, node: m.To, msg: m})
+	}
+}
+
+func (e env) After(d time.Duration, t protocol.Timer) {
+	e.s.push(&event{at: e.s.now + d, kind: fire, node: e.n.id, incarnation: e.n.incarnation, timer: t})
+}
+
+//Decided检查原子性：同一轮所有节点的决定必须相同，提交的轮次所有参与者都必须投过同意票，
+//同一个高度上最多提交一轮
+func (e env) Decided(round protocol.Round, height uint64, o protocol.Outcome) {
+	s := e.s
+	s.trace("%s decided r%s h%d %s", e.n.id, round, height, o)
+	if first, ok := s.outcomes[round]; !ok {
+		s.outcomes[round], s.decider[round] = o, e.n.id
+	} else if first != o {
+		s.violate("r%s: %s %s but %s %s", round, s.decider[round], first, e.n.id, o)
+	}
+	if o == protocol.Committed {
+		if s.no[round] || len(s.yes[round]) < s.cfg.Participants {
+			s.violate("r%s: %s committed without all participants voting yes", round, e.n.id)
+		}
+		if other, ok := s.committed[height]; !ok {
+			s.committed[height] = round
+		} else if other != round {
+			s.violate("h%d: %s committed r%s after r%s", height, e.n.id, round, other)
+		}
+	}
+	if e.n.id != coordinatorID {
+		return
+	}
+	if o == protocol.Committed && height >= s.height {
+		s.height = height + 1
+	}
+	if s.current != nil && *s.current == round {
+		s.current = nil
+		s.push(&event{at: s.now, kind: begin, node: coordinatorID, incarnation: e.n.incarnation})
+	}
+}
+
+func (s *simulator) delay() time.Duration {
+	d := s.cfg.MinDelay
+	if span := s.cfg.MaxDelay - s.cfg.MinDelay; span > 0 {
+		d += time.Duration(s.rnd.Int63n(int64(span) + 1))
+	}
+	return d
+}
+
+func (s *simulator) push(e *event) {
+	s.seq++
+	e.seq = s.seq
+	heap.Push(&s.events, e)
+}
+
+func (s *simulator) trace(format string, args ...interface{}) {
+	line := fmt.Sprintf("%10s  ", s.now) + fmt.Sprintf(format, args...)
+	s.result.Trace = append(s.result.Trace, line)
+	if len(s.result.Trace) > s.cfg.TraceSize {
+		s.result.Trace = s.result.Trace[1:]
+	}
+}
+
+func (s *simulator) violate(format string, args ...interface{}) {
+	v := fmt.Sprintf(format, args...)
+	s.trace("VIOLATION %s", v)
+	s.result.Violations = append(s.result.Violations, v)
+}
+
+//start新建节点的内存状态，从它的日志恢复
+func (s *simulator) start(n *node) {
+	if n.id == coordinatorID {
+		n.impl = &protocol.Coordinator{
+			ID:       n.id,
+			Mode:     s.cfg.Mode,
+			Timeouts: s.cfg.Timeouts,
+			Log:      n.log,
+		}
+	} else {
+		n.impl = &protocol.Participant{
+			ID:       n.id,
+			Mode:     s.cfg.Mode,
+			Timeouts: s.cfg.Timeouts,
+			Vote:     s.vote,
+			Log:      n.log,
+		}
+	}
+	n.up = true
+	n.impl.Start(env{s: s, n: n})
+	if n.id == coordinatorID {
+		s.push(&event{at: s.now, kind: begin, node: n.id, incarnation: n.incarnation})
+	}
+}
+
+//vote记下参与者的投票，一阶段提交的参与者投票之后马上提交
+func (s *simulator) vote(m protocol.Message) protocol.Ballot {
+	if s.rnd.Float64() < s.cfg.NoVote {
+		s.no[m.Round] = true
+		return protocol.Ballot{}
+	}
+	if s.yes[m.Round] == nil {
+		s.yes[m.Round] = map[string]bool{}
+	}
+	s.yes[m.Round][m.To] = true
+	return protocol.Ballot{Yes: true, ReadOnly: s.rnd.Float64() < s.cfg.ReadOnly}
+}
+
+//next在coordinator提交到的高度上开始下一轮，高度上还有不知道结果的一轮时稍后再试
+func (s *simulator) next(n *node) {
+	if s.current != nil || len(s.rounds) >= s.cfg.Rounds {
+		return
+	}
+	c := n.impl.(*protocol.Coordinator)
+	if c.Busy(s.height) {
+		s.push(&event{at: s.now + s.cfg.Timeouts.Vote, kind: begin, node: n.id, incarnation: n.incarnation})
+		return
+	}
+	participants := make([]string, 0, s.cfg.Participants)
+	for _, p := range s.nodes[1:] {
+		participants = append(participants, p.id)
+	}
+	round := c.NewRound()
+	s.rounds = append(s.rounds, round)
+	s.current = &round
+	s.trace("begin r%s h%d", round, s.height)
+	c.Begin(env{s: s, n: n}, round, s.height, participants)
+}
+
+//requests是没有回应时由Env报告Failed的请求
+var requests = map[protocol.Kind]bool{
+	protocol.Propose:   true,
+	protocol.Precommit: true,
+	protocol.Commit:    true,
+	protocol.Abort:     true,
+	protocol.Query:     true,
+	protocol.Resolve:   true,
+}
+
+func (s *simulator) crash() {
+	var up []*node
+	for _, n := range s.nodes {
+		if n.up {
+			up = append(up, n)
+		}
+	}
+	if len(up) == 0 {
+		return
+	}
+	n := up[s.rnd.Intn(len(up))]
+	n.up = false
+	n.incarnation++
+	n.log.Crash()
+	if n.id == coordinatorID {
+		s.current = nil
+	}
+	downtime := s.cfg.MinDowntime
+	if span := s.cfg.MaxDowntime - s.cfg.MinDowntime; span > 0 {
+		downtime += time.Duration(s.rnd.Int63n(int64(span) + 1))
+	}
+	s.trace("crash %s for %s", n.id, downtime)
+	s.push(&event{at: s.now + downtime, kind: restart, node: n.id})
+}
+
+func (s *simulator) step(e *event) {
+	s.now = e.at
+	n := s.byID[e.node]
+	switch e.kind {
+	case restart:
+		s.trace("restart %s", n.id)
+		s.start(n)
+		return
+	case begin:
+		if !n.up || e.incarnation != n.incarnation {
+			return
+		}
+		s.next(n)
+	case deliver:
+		if !n.up {
+			s.trace("lost %s (node down)", e.msg)
+			//连接不上的请求由发送方的Env报告失败
+			if m := e.msg; requests[m.Kind] && s.byID[m.From].up {
+				s.push(&event{at: s.now + s.delay(), kind: deliver, node: m.From, msg: protocol.Message{
+					Kind: protocol.Failed, From: m.To, To: m.From, Round: m.Round, Height: m.Height, Request: m.Kind,
+				}})
+			}
+			return
+		}
+		s.trace("%s", e.msg)
+		n.impl.Handle(env{s: s, n: n}, e.msg)
+	case fire:
+		if !n.up || e.incarnation != n.incarnation {
+			return
+		}
+		s.trace("%s fire %s", n.id, e.timer)
+		n.impl.Fire(env{s: s, n: n}, e.timer)
+	}
+	if s.rnd.Float64() < s.cfg.CrashRate {
+		s.crash()
+	}
+}
+
+//Run用seed跑一次调度，直到没有事件或者到达时间上限
+func Run(cfg Config, seed int64) Result {
+	s := &simulator{
+		cfg:       cfg,
+		rnd:       rand.New(rand.NewSource(seed)),
+		byID:      map[string]*node{},
+		outcomes:  map[protocol.Round]protocol.Outcome{},
+		decider:   map[protocol.Round]string{},
+		yes:       map[protocol.Round]map[string]bool{},
+		no:        map[protocol.Round]bool{},
+		committed: map[uint64]protocol.Round{},
+		result:    Result{Seed: seed},
+	}
+	ids := []string{coordinatorID}
+	for i := 1; i <= cfg.Participants; i++ {
+		ids = append(ids, fmt.Sprintf("p%d", i))
+	}
+	for _, id := range ids {
+		n := &node{id: id, log: &protocol.MemLog{}}
+		s.nodes = append(s.nodes, n)
+		s.byID[id] = n
+	}
+	for _, n := range s.nodes {
+		s.start(n)
+	}
+	for s.events.Len() > 0 {
+		e := heap.Pop(&s.events).(*event)
+		if e.at > cfg.MaxTime {
+			break
+		}
+		s.step(e)
+		s.result.Steps++
+	}
+
+	s.result.Time = s.now
+	for _, round := range s.rounds {
+		switch s.outcomes[round] {
+		case protocol.Committed:
+			s.result.Committed++
+		case protocol.Aborted:
+			s.result.Aborted++
+		default:
+			s.result.Undecided++
+		}
+	}
+	return s.result
+}
+
+//Explore从seed开始依次跑runs个调度，返回第一个违反原子性的调度，都没有问题时返回nil
+func Explore(cfg Config, seed int64, runs int) *Result {
+	for i := 0; i < runs; i++ {
+		if r := Run(cfg, seed+int64(i)); !r.Ok() {
+			return &r
+		}
+	}
+	return nil
+}
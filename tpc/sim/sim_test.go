@@ -0,0 +1,55 @@
+package sim
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sysphusking/dsts/2pc/protocol"
+)
+
+func runs() int {
+	if testing.Short() {
+		return 50
+	}
+	return 500
+}
+
+//每次都从同样的seed开始，失败的调度可以用examples/sim重放；只有一个参与者时跑的是一阶段提交
+func TestExplore(t *testing.T) {
+	for _, mode := range []protocol.Mode{protocol.TwoPhase, protocol.PresumedAbort, protocol.PresumedCommit} {
+		for _, participants := range []int{1, 3} {
+			mode, participants := mode, participants
+			t.Run(fmt.Sprintf("%s/%d", mode, participants), func(t *testing.T) {
+				cfg := DefaultConfig()
+				cfg.Mode = mode
+				cfg.Participants = participants
+				if failed := Explore(cfg, 1, runs()); failed != nil {
+					t.Errorf("seed %d violates atomicity after %d steps:\n  %s\nreplay with go run ./examples/sim -committype %s -participants %d -seed %d -runs 1 -v",
+						failed.Seed, failed.Steps, strings.Join(failed.Violations, "\n  "), mode, participants, failed.Seed)
+				}
+			})
+		}
+	}
+}
+
+//3PC在回滚没有送到时会自动提交，模拟必须能找到这样的调度
+func TestExploreThreePhase(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Mode = protocol.ThreePhase
+	if Explore(cfg, 1, runs()) == nil {
+		t.Errorf("no atomicity violation found in %d schedules of three-phase", runs())
+	}
+}
+
+//同一个seed总是得到同样的调度
+func TestRunDeterministic(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Mode = protocol.ThreePhase
+	for seed := int64(1); seed <= 20; seed++ {
+		if a, b := Run(cfg, seed), Run(cfg, seed); !reflect.DeepEqual(a, b) {
+			t.Fatalf("seed %d: two runs differ after %d and %d steps", seed, a.Steps, b.Steps)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package snapshot
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+//快照文件格式：第一行是json格式的header，后面紧跟size个字节的body，body是json格式的Entry列表
+//header里记录了body的sha256，读取时会校验，运维可以直接拷贝快照文件做备份
+const (
+	filePrefix = "snapshot-"
+	fileSuffix = ".snap"
+)
+
+var ErrChecksum = errors.New("snapshot checksum mismatch")
+
+type Entry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	Index uint64 `json:"index"`
+}
+
+//Snapshot是某个高度之前（不包含Height）所有已提交数据的最终状态
+type Snapshot struct {
+	Height  uint64
+	Entries []Entry
+}
+
+type header struct {
+	Height uint64 `json:"height"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+//Write把快照写到dir下，先写临时文件再rename，避免留下写了一半的快照
+func Write(dir string, snap *Snapshot) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s%020d%s", filePrefix, snap.Height, fileSuffix))
+	if err := WriteFile(path, snap); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func WriteFile(path string, snap *Snapshot) error {
+	body, err := json.Marshal(snap.Entries)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	head, err := json.Marshal(header{Height: snap.Height, Size: int64(len(body)), Sha256: hex.EncodeToString(sum[:])})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	w.Write(head)
+	w.WriteByte('\n')
+	w.Write(body)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+//Read读取并校验快照文件
+func Read(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot header")
+	}
+	var head header
+	if err := json.Unmarshal(line, &head); err != nil {
+		return nil, errors.Wrap(err, "invalid snapshot header")
+	}
+	body := make([]byte, head.Size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot body")
+	}
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != head.Sha256 {
+		return nil, ErrChecksum
+	}
+
+	snap := &Snapshot{Height: head.Height}
+	if err := json.Unmarshal(body, &snap.Entries); err != nil {
+		return nil, errors.Wrap(err, "invalid snapshot body")
+	}
+	return snap, nil
+}
+
+//List按高度从小到大返回dir下所有的快照文件
+func List(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	//文件名里的高度是补零的，字典序就是高度顺序
+	sort.Strings(paths)
+	return paths, nil
+}
+
+//Latest返回dir下高度最大的快照文件，没有时返回空字符串
+func Latest(dir string) (string, error) {
+	paths, err := List(dir)
+	if err != nil || len(paths) == 0 {
+		return "", err
+	}
+	return paths[len(paths)-1], nil
+}
+
+//Prune只保留最新的keep个快照文件
+func Prune(dir string, keep int) error {
+	paths, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(paths)-keep; i++ {
+		if err := os.Remove(paths[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
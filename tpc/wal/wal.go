@@ -0,0 +1,167 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/sysphusking/dsts/2pc/cache"
+	"github.com/sysphusking/dsts/2pc/metrics"
+)
+
+//协议日志的记录类型
+type RecordType string
+
+const (
+	//presumed-commit和一阶段提交的coordinator在propose之前强制写入，记录参与者
+	Collecting RecordType = "collecting"
+	//参与者投票之前强制写入，带上要提交的数据，崩溃后可以继续提交
+	Prepared RecordType = "prepared"
+	Commit   RecordType = "commit"
+	Abort    RecordType = "abort"
+	//coordinator收齐需要的ack之后写入，之后就可以忘掉这个事务
+	End RecordType = "end"
+	//coordinator每次启动写入新的epoch，Index是epoch，之后的事务id都比崩溃之前的大
+	Boot RecordType = "boot"
+)
+
+type Record struct {
+	Type         RecordType  `json:"type"`
+	TxID         string      `json:"txid"`
+	Index        uint64      `json:"index"`
+	CommitType   string      `json:"commit_type,omitempty"`
+	Participants []string    `json:"participants,omitempty"`
+	Msgs         []cache.Msg `json:"msgs,omitempty"`
+	//只有一个参与者的事务，coordinator重启之后要问出它的结果
+	OnePhase bool `json:"one_phase,omitempty"`
+	//参与者写的记录，和coordinator的记录在同一个日志里
+	Participant bool `json:"participant,omitempty"`
+	//参与者记录的coordinator，不确定时向它询问结果
+	Coordinator string `json:"coordinator,omitempty"`
+}
+
+//Log是追加写的协议日志，每行一条json记录，force的记录会fsync之后才返回
+type Log struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	mu   sync.Mutex
+}
+
+//Open打开日志并返回已有的记录，文件不存在时创建
+func Open(path string) (*Log, []Record, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, err
+	}
+	records, err := read(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Log{path: path, f: f, w: bufio.NewWriter(f)}, records, nil
+}
+
+func read(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			//最后一行可能在崩溃时只写了一半，之前的记录都是完整的
+			break
+		}
+		records = append(records, rec)
+	}
+	return records, errors.Wrap(scanner.Err(), "failed to read wal")
+}
+
+//Append追加一条记录，force为true时落盘之后才返回，否则只写进缓冲区，由之后的force一起落盘
+func (l *Log) Append(rec Record, force bool) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(b)
+	if err := l.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	metrics.WalWrites.Add(1)
+	if !force {
+		return nil
+	}
+	metrics.WalForcedWrites.Add(1)
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+//Rewrite用records替换日志内容，启动恢复之后用来丢掉已经结束的事务
+func (l *Log) Rewrite(records []Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tmp := l.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, rec := range records {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	l.f.Close()
+	if l.f, err = os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+		return err
+	}
+	l.w = bufio.NewWriter(l.f)
+	return nil
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}